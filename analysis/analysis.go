@@ -0,0 +1,198 @@
+// Package analysis embeds the import cycle analysis of goimportcycle in other
+// programs. Analyze walks and parses the packages of a module like the
+// goimportcycle command and returns its packages, files, edges, and import
+// cycles.
+package analysis
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/samlitowitz/goimportcycle/internal/analyzer"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/pkgpattern"
+	"github.com/samlitowitz/goimportcycle/internal/platform"
+)
+
+// EdgeMode selects which imports are edges of the import graph.
+type EdgeMode int
+
+const (
+	// DeclarationEdges are imports with a referenced declaration.
+	DeclarationEdges EdgeMode = iota
+	// ImportEdges are every import, like the compiler.
+	ImportEdges
+)
+
+// DefaultMaxCycles is the number of import cycles enumerated when
+// Options.MaxCycles is zero.
+const DefaultMaxCycles = config.DefaultMaxCycles
+
+// Options configure an analysis, the zero value analyzes every package of
+// the module containing the current directory for the host platform.
+type Options struct {
+	// Path is a directory of the module to analyze, the current directory
+	// when empty.
+	Path string
+	// Patterns are go package patterns, e.g. ./internal/..., relative to
+	// Path. The matched packages and the in-module packages they import are
+	// analyzed, every package when empty.
+	Patterns []string
+
+	Edges EdgeMode
+	// Tests includes _test.go files and external _test packages.
+	Tests bool
+	// Typecheck resolves references with go/types instead of import names.
+	Typecheck bool
+
+	// Include limits the analyzed directories to those matching one of the
+	// glob patterns, relative to the module root, and the directories below
+	// them.
+	Include []string
+	// Exclude skips the directories matching one of the glob patterns,
+	// relative to the module root.
+	Exclude []string
+	// GitIgnore skips the directories ignored by .gitignore files.
+	GitIgnore bool
+	// Binary limits the result to the packages reachable from the main
+	// package in this directory, relative to the module root.
+	Binary string
+
+	// GOOS, GOARCH, and Tags select the files of a package like the go tool,
+	// defaulting to the environment and the host platform.
+	GOOS   string
+	GOARCH string
+	Tags   []string
+
+	// MaxCycles limits how many import cycles are enumerated, DefaultMaxCycles
+	// when zero and every cycle when negative.
+	MaxCycles int
+
+	// KeepGoing skips the directories, files, and declarations which cannot
+	// be analyzed, listing them in Result.Diagnostics, instead of failing
+	// with a *ParseError or *NodeError.
+	KeepGoing bool
+
+	// NoCache parses every file instead of reading the facts of unchanged
+	// files from the cache.
+	NoCache bool
+	// CacheDir is the directory of the cache, goimportcycle in the user
+	// cache directory when empty.
+	CacheDir string
+
+	// LowMemory drops the syntax tree of each file right after extracting
+	// its facts, bounding memory use on very large modules. It cannot be
+	// used with Typecheck, which needs the syntax trees of the whole module,
+	// analysis fails with a *ConflictError.
+	LowMemory bool
+}
+
+// Result is the analyzed module.
+type Result struct {
+	ModulePath    string
+	ModuleRootDir string
+
+	// Packages are the analyzed packages ordered by directory, packages
+	// outside the module are only referenced by imports.
+	Packages []*Package
+	// PackageEdges are the imports between analyzed packages, aggregated
+	// per pair of packages.
+	PackageEdges []*PackageEdge
+	// FileEdges are the references from a file to the declarations of
+	// another analyzed file, aggregated per pair of files.
+	FileEdges []*FileEdge
+
+	// PackageCycles are the elementary import cycles between packages, with
+	// ImportEdges the import cycles the compiler rejects.
+	PackageCycles []*Cycle
+	// FileCycles are the elementary import cycles between files.
+	FileCycles []*Cycle
+	// CyclesLimitReached is set when more cycles exist than were enumerated.
+	CyclesLimitReached bool
+
+	// Warnings did not stop the analysis but may make it incomplete.
+	Warnings []string
+	// Diagnostics are what was skipped with Options.KeepGoing, ordered by
+	// position.
+	Diagnostics []*Diagnostic
+}
+
+// Analyze analyzes the module containing opts.Path. Analysis stops with the
+// error of ctx once it is done.
+func Analyze(ctx context.Context, opts Options) (*Result, error) {
+	path := opts.Path
+	if path == "" {
+		path = "."
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	module, err := analyzer.FindModule(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns *pkgpattern.Set
+	if len(opts.Patterns) > 0 {
+		patterns, err = pkgpattern.Parse(module.Path, module.RootDir, absPath, opts.Patterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := opts.config()
+	analyzed, err := analyzer.Analyze(ctx, cfg, module, patterns, opts.platform())
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	result := convert(cfg, module, analyzed.Packages)
+	result.Warnings = analyzed.Warnings
+	for _, diagnostic := range analyzed.Diagnostics {
+		result.Diagnostics = append(result.Diagnostics, &Diagnostic{
+			Pos:     diagnostic.Pos,
+			Skipped: diagnostic.Skipped,
+			Err:     convertError(diagnostic.Err),
+		})
+	}
+	return result, nil
+}
+
+func (opts Options) config() *config.Config {
+	cfg := config.Default()
+	if opts.Edges == ImportEdges {
+		cfg.Edges = config.ImportEdges
+	}
+	switch {
+	case opts.MaxCycles < 0:
+		cfg.MaxCycles = 0
+	case opts.MaxCycles > 0:
+		cfg.MaxCycles = opts.MaxCycles
+	}
+	cfg.Tests = opts.Tests
+	cfg.Typecheck = opts.Typecheck
+	cfg.Include = opts.Include
+	cfg.Exclude = opts.Exclude
+	cfg.GitIgnore = opts.GitIgnore
+	cfg.Binary = opts.Binary
+	cfg.KeepGoing = opts.KeepGoing
+	cfg.NoCache = opts.NoCache
+	cfg.CacheDir = opts.CacheDir
+	cfg.LowMemory = opts.LowMemory
+	return cfg
+}
+
+func (opts Options) platform() platform.Platform {
+	p := platform.Default()
+	if opts.GOOS != "" {
+		p.GOOS = opts.GOOS
+	}
+	if opts.GOARCH != "" {
+		p.GOARCH = opts.GOARCH
+	}
+	if opts.Tags != nil {
+		p.Tags = opts.Tags
+	}
+	return p
+}
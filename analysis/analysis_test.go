@@ -0,0 +1,267 @@
+package analysis_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/analysis"
+)
+
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	moduleRootDir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(moduleRootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return moduleRootDir
+}
+
+func TestAnalyze(t *testing.T) {
+	moduleRootDir := writeModule(t, map[string]string{
+		"go.mod":          "module example.com/m\n\ngo 1.21\n",
+		"cmd/api/main.go": "package main\n\nimport \"example.com/m/a\"\n\nfunc main() { a.A() }\n",
+		"a/a.go":          "package a\n\nimport \"example.com/m/b\"\n\nfunc A() { b.B() }\n",
+		"b/b.go":          "package b\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/m/a\"\n)\n\nfunc B() { fmt.Println(a.C) }\n",
+		"a/c.go":          "package a\n\nconst C = 1\n",
+	})
+
+	result, err := analysis.Analyze(context.Background(), analysis.Options{Path: moduleRootDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ModulePath != "example.com/m" {
+		t.Errorf("module path: expected example.com/m, got %s", result.ModulePath)
+	}
+
+	var pkgs []string
+	for _, pkg := range result.Packages {
+		pkgs = append(pkgs, pkg.ImportPath)
+	}
+	expectedPkgs := []string{"example.com/m/a", "example.com/m/b", "example.com/m/cmd/api"}
+	if !slices.Equal(expectedPkgs, pkgs) {
+		t.Errorf("packages: expected %v, got %v", expectedPkgs, pkgs)
+	}
+
+	var pkgEdges []string
+	for _, edge := range result.PackageEdges {
+		pkgEdges = append(pkgEdges, edge.From.Path+" -> "+edge.To.Path)
+	}
+	expectedPkgEdges := []string{"a -> b", "b -> a", "cmd/api:main -> a"}
+	if !slices.Equal(expectedPkgEdges, pkgEdges) {
+		t.Errorf("package edges: expected %v, got %v", expectedPkgEdges, pkgEdges)
+	}
+
+	var fileEdges []string
+	for _, edge := range result.FileEdges {
+		fileEdges = append(fileEdges, edge.From.Path+" -> "+edge.To.Path)
+	}
+	expectedFileEdges := []string{"a/a.go -> b/b.go", "b/b.go -> a/c.go", "cmd/api/main.go -> a/a.go"}
+	if !slices.Equal(expectedFileEdges, fileEdges) {
+		t.Errorf("file edges: expected %v, got %v", expectedFileEdges, fileEdges)
+	}
+
+	if len(result.PackageCycles) != 1 {
+		t.Fatalf("package cycles: expected 1, got %d", len(result.PackageCycles))
+	}
+	var hops []string
+	for _, hop := range result.PackageCycles[0].Hops {
+		hops = append(hops, hop.From.ModuleRelativePath()+" -> "+hop.To.ModuleRelativePath())
+	}
+	expectedHops := []string{"a -> b", "b -> a"}
+	if !slices.Equal(expectedHops, hops) {
+		t.Errorf("package cycle: expected %v, got %v", expectedHops, hops)
+	}
+	for _, hop := range result.PackageCycles[0].Hops {
+		if hop.From.ModuleRelativePath() != "b" {
+			continue
+		}
+		if len(hop.Imports) != 1 || len(hop.Imports[0].Uses) != 1 {
+			t.Fatalf("b -> a: expected a single import with a single use, got %v", hop.Imports)
+		}
+		imp := hop.Imports[0]
+		if imp.Pos.Line != 6 || imp.Pos.Column != 2 {
+			t.Errorf("b -> a: expected import at 6:2, got %s", imp.Pos)
+		}
+		use := imp.Uses[0]
+		if use.Decl.Name != "C" || use.Pos.Line != 9 || use.Pos.Column != 26 {
+			t.Errorf("b -> a: expected a.C used at 9:26, got %s used at %s", use.Decl.Name, use.Pos)
+		}
+		if use.Decl.Pos.Line != 3 || use.Decl.Pos.Column != 7 {
+			t.Errorf("a.C: expected declaration at 3:7, got %s", use.Decl.Pos)
+		}
+	}
+	if len(result.FileCycles) != 0 {
+		t.Errorf("file cycles: expected 0, got %d", len(result.FileCycles))
+	}
+}
+
+func TestAnalyze_KeepGoing(t *testing.T) {
+	moduleRootDir := writeModule(t, map[string]string{
+		"go.mod":   "module example.com/m\n\ngo 1.21\n",
+		"a/a.go":   "package a\n\nimport \"example.com/m/b\"\n\nfunc A() { b.B() }\n\nfunc init() {}\n\nfunc init() {}\n",
+		"b/b.go":   "package b\n\nimport \"example.com/m/a\"\n\nfunc B() { a.A() }\n",
+		"b/bad.go": "package b\n\nfunc C() {\n",
+	})
+
+	_, err := analysis.Analyze(context.Background(), analysis.Options{Path: moduleRootDir})
+	var parseErr *analysis.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *analysis.ParseError, got %v", err)
+	}
+	if filepath.Base(parseErr.Pos.Filename) != "bad.go" || parseErr.Pos.Line != 3 {
+		t.Errorf("expected error at bad.go:3, got %s", parseErr.Pos)
+	}
+
+	result, err := analysis.Analyze(context.Background(), analysis.Options{Path: moduleRootDir, KeepGoing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Diagnostics) != 1 {
+		t.Fatalf("diagnostics: expected 1, got %d", len(result.Diagnostics))
+	}
+	diagnostic := result.Diagnostics[0]
+	if diagnostic.Skipped != "file" || filepath.Base(diagnostic.Pos.Filename) != "bad.go" {
+		t.Errorf("expected bad.go to be skipped, got %s %s", diagnostic.Skipped, diagnostic.Pos)
+	}
+	if _, ok := diagnostic.Err.(*analysis.ParseError); !ok {
+		t.Errorf("expected *analysis.ParseError, got %T", diagnostic.Err)
+	}
+	if len(result.PackageCycles) != 1 {
+		t.Errorf("package cycles: expected 1, got %d", len(result.PackageCycles))
+	}
+}
+
+func TestAnalyze_KeepGoingPatterns(t *testing.T) {
+	moduleRootDir := writeModule(t, map[string]string{
+		"go.mod":   "module example.com/m\n\ngo 1.21\n",
+		"a/a.go":   "package a\n\nimport \"example.com/m/b\"\n\nfunc A() { b.B() }\n",
+		"b/b.go":   "package b\n\nimport \"example.com/m/a\"\n\nfunc B() { a.A() }\n",
+		"c/c.go":   "package c\n\nimport \"example.com/m/a\"\n\nfunc C() { a.A() }\n",
+		"c/bad.go": "package\n",
+	})
+	opts := analysis.Options{Path: moduleRootDir, Patterns: []string{"./..."}}
+
+	_, err := analysis.Analyze(context.Background(), opts)
+	var parseErr *analysis.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *analysis.ParseError, got %v", err)
+	}
+
+	opts.KeepGoing = true
+	result, err := analysis.Analyze(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Diagnostics) != 1 {
+		t.Fatalf("diagnostics: expected 1, got %d", len(result.Diagnostics))
+	}
+	diagnostic := result.Diagnostics[0]
+	if diagnostic.Skipped != "directory" || filepath.Base(diagnostic.Pos.Filename) != "c" {
+		t.Errorf("expected c to be skipped, got %s %s", diagnostic.Skipped, diagnostic.Pos)
+	}
+	if len(result.PackageCycles) != 1 {
+		t.Errorf("package cycles: expected 1, got %d", len(result.PackageCycles))
+	}
+}
+
+func TestAnalyze_Cache(t *testing.T) {
+	moduleRootDir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": "package a\n\nimport \"example.com/m/b\"\n\nfunc A() { b.B() }\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nfunc B() { a.A() }\n",
+	})
+	opts := analysis.Options{Path: moduleRootDir, CacheDir: t.TempDir()}
+
+	analyze := func() *analysis.Result {
+		t.Helper()
+		result, err := analysis.Analyze(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	for i := 0; i < 2; i++ {
+		result := analyze()
+		if len(result.PackageCycles) != 1 {
+			t.Fatalf("run %d: package cycles: expected 1, got %d", i, len(result.PackageCycles))
+		}
+		use := result.PackageCycles[0].Hops[0].Imports[0].Uses[0]
+		if use.Pos.Line != 5 || use.Pos.Column != 14 {
+			t.Errorf("run %d: expected b.B used at 5:14, got %s", i, use.Pos)
+		}
+	}
+
+	// a/a.go is unchanged, but b is no longer named b so b.B is no longer a
+	// reference to it
+	err := os.WriteFile(
+		filepath.Join(moduleRootDir, "b", "b.go"),
+		[]byte("package bee\n\nimport \"example.com/m/a\"\n\nfunc B() { a.A() }\n"),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := analyze()
+	if len(result.PackageCycles) != 0 {
+		t.Errorf("package cycles: expected 0 once b is renamed, got %d", len(result.PackageCycles))
+	}
+}
+
+func TestAnalyze_LowMemory(t *testing.T) {
+	moduleRootDir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": "package a\n\nimport \"example.com/m/b\"\n\nfunc A() { b.B() }\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nfunc B() { a.A() }\n",
+	})
+
+	// without a cache the facts are only extracted to drop the syntax trees
+	result, err := analysis.Analyze(context.Background(), analysis.Options{
+		Path:      moduleRootDir,
+		NoCache:   true,
+		LowMemory: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.PackageCycles) != 1 {
+		t.Fatalf("package cycles: expected 1, got %d", len(result.PackageCycles))
+	}
+	use := result.PackageCycles[0].Hops[0].Imports[0].Uses[0]
+	if use.Pos.Line != 5 || use.Pos.Column != 14 {
+		t.Errorf("expected b.B used at 5:14, got %s", use.Pos)
+	}
+
+	_, err = analysis.Analyze(context.Background(), analysis.Options{
+		Path:      moduleRootDir,
+		LowMemory: true,
+		Typecheck: true,
+	})
+	var conflictErr *analysis.ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Errorf("expected a *ConflictError, got %v", err)
+	}
+}
+
+func TestAnalyze_Cancelled(t *testing.T) {
+	moduleRootDir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": "package a\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := analysis.Analyze(ctx, analysis.Options{Path: moduleRootDir})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
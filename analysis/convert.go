@@ -0,0 +1,305 @@
+package analysis
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/analyzer"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/cycle"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+// converter converts the internal primitives to the exported types, every
+// primitive is converted once so the result shares pointers like the
+// primitives do.
+type converter struct {
+	pkgs  map[*internal.Package]*Package
+	files map[*internal.File]*File
+	decls map[*internal.Decl]*Decl
+	imps  map[*internal.Import]*Import
+}
+
+func convert(cfg *config.Config, module *analyzer.Module, pkgs []*internal.Package) *Result {
+	conv := &converter{
+		pkgs:  make(map[*internal.Package]*Package),
+		files: make(map[*internal.File]*File),
+		decls: make(map[*internal.Decl]*Decl),
+		imps:  make(map[*internal.Import]*Import),
+	}
+	result := &Result{
+		ModulePath:    module.Path,
+		ModuleRootDir: module.RootDir,
+	}
+
+	analyzed := make([]*internal.Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		if pkg.IsStub {
+			continue
+		}
+		analyzed = append(analyzed, pkg)
+	}
+	// packages and files first so imports can point at any of them
+	for _, pkg := range analyzed {
+		result.Packages = append(result.Packages, conv.pkg(pkg))
+	}
+	slices.SortFunc(result.Packages, comparePackages)
+	for _, pkg := range analyzed {
+		for _, file := range sortedFiles(pkg) {
+			conv.imports(file)
+		}
+	}
+
+	result.PackageEdges = conv.packageEdges(analyzed)
+	result.FileEdges = conv.fileEdges(cfg, analyzed)
+
+	var pkgCycles, fileCycles []*cycle.Cycle
+	var pkgLimitReached, fileLimitReached bool
+	if cfg.Edges == config.ImportEdges {
+		pkgCycles, pkgLimitReached = cycle.Imports(pkgs, cfg.MaxCycles)
+	} else {
+		pkgCycles, pkgLimitReached = cycle.Packages(pkgs, cfg.MaxCycles)
+	}
+	fileCycles, fileLimitReached = cycle.Files(pkgs, cfg.MaxCycles)
+	result.PackageCycles = conv.cycles(pkgCycles)
+	result.FileCycles = conv.cycles(fileCycles)
+	result.CyclesLimitReached = pkgLimitReached || fileLimitReached
+
+	return result
+}
+
+func (conv *converter) pkg(pkg *internal.Package) *Package {
+	converted := &Package{
+		ImportPath:     pkg.DirImportPath(),
+		Name:           pkg.Name,
+		Dir:            pkg.DirName,
+		Path:           pkg.ModuleRelativePath(),
+		IsExternalTest: pkg.IsExternalTest,
+		OutOfScope:     pkg.OutOfScope,
+		InImportCycle:  pkg.InImportCycle,
+	}
+	conv.pkgs[pkg] = converted
+
+	for _, file := range sortedFiles(pkg) {
+		convertedFile := &File{
+			Package:       converted,
+			Name:          file.FileName,
+			AbsPath:       file.AbsPath,
+			Path:          file.ModuleRelativePath(),
+			Test:          file.IsTest(),
+			Cgo:           file.IsCgo,
+			InImportCycle: file.InImportCycle,
+		}
+		conv.files[file] = convertedFile
+		converted.Files = append(converted.Files, convertedFile)
+
+		decls := make([]*internal.Decl, 0, len(file.Decls))
+		for _, decl := range file.Decls {
+			decls = append(decls, decl)
+		}
+		for _, decl := range sortedDecls(decls) {
+			convertedFile.Decls = append(convertedFile.Decls, conv.decl(decl))
+		}
+	}
+	return converted
+}
+
+func (conv *converter) decl(decl *internal.Decl) *Decl {
+	if converted, ok := conv.decls[decl]; ok {
+		return converted
+	}
+	converted := &Decl{
+		// nil for declarations of packages which are not analyzed
+		File: conv.files[decl.File],
+		Name: decl.QualifiedName(),
+		Pos:  decl.Pos,
+	}
+	conv.decls[decl] = converted
+	return converted
+}
+
+func (conv *converter) imports(file *internal.File) {
+	convertedFile := conv.files[file]
+	for _, imp := range sortedImports(file) {
+		converted := &Import{
+			File:          convertedFile,
+			Name:          imp.Name,
+			Path:          imp.Path,
+			Pos:           imp.Pos,
+			Package:       conv.pkgs[imp.Package],
+			IsBlank:       imp.IsBlankImport(),
+			IsDot:         imp.IsDotImport(),
+			IsLinkname:    imp.IsLinkname,
+			InImportCycle: imp.InImportCycle,
+		}
+		for _, decl := range referencedDecls(imp) {
+			converted.Referenced = append(converted.Referenced, conv.decl(decl))
+		}
+		for _, use := range sortedUses(imp) {
+			converted.Uses = append(converted.Uses, &Use{
+				Decl: conv.decl(use.Decl),
+				Pos:  use.Pos,
+			})
+		}
+		conv.imps[imp] = converted
+		convertedFile.Imports = append(convertedFile.Imports, converted)
+	}
+}
+
+func (conv *converter) packageEdges(pkgs []*internal.Package) []*PackageEdge {
+	var edges []*PackageEdge
+	for _, e := range graph.Packages(pkgs, config.ImportEdges).Edges() {
+		edge := &PackageEdge{
+			From: conv.pkgs[e.From.Package],
+			To:   conv.pkgs[e.To.Package],
+		}
+		for _, prov := range e.Provenance {
+			edge.Imports = append(edge.Imports, conv.imps[prov.Import])
+			edge.InImportCycle = edge.InImportCycle || prov.Import.InImportCycle
+		}
+		edges = append(edges, edge)
+	}
+	slices.SortStableFunc(edges, func(a, b *PackageEdge) int {
+		if c := comparePackages(a.From, b.From); c != 0 {
+			return c
+		}
+		return comparePackages(a.To, b.To)
+	})
+	return edges
+}
+
+func (conv *converter) fileEdges(cfg *config.Config, pkgs []*internal.Package) []*FileEdge {
+	var edges []*FileEdge
+	for _, e := range graph.Files(pkgs).Edges() {
+		edge := &FileEdge{
+			From: conv.files[e.From.File],
+			To:   conv.files[e.To.File],
+		}
+		for _, decl := range e.Decls() {
+			edge.Decls = append(edge.Decls, conv.decls[decl])
+		}
+		for _, prov := range e.Provenance {
+			_, inCycle := prov.Import.ReferencedFilesInCycle[e.To.File.UID()]
+			if cfg.Edges == config.ImportEdges && prov.Import.InImportCycle {
+				inCycle = true
+			}
+			edge.InImportCycle = edge.InImportCycle || inCycle
+		}
+		edges = append(edges, edge)
+	}
+	slices.SortStableFunc(edges, func(a, b *FileEdge) int {
+		if c := cmp.Compare(a.From.AbsPath, b.From.AbsPath); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.To.AbsPath, b.To.AbsPath)
+	})
+	return edges
+}
+
+func (conv *converter) cycles(cycles []*cycle.Cycle) []*Cycle {
+	converted := make([]*Cycle, 0, len(cycles))
+	for _, c := range cycles {
+		convertedCycle := &Cycle{
+			Hops: make([]*Hop, 0, len(c.Hops)),
+		}
+		for _, hop := range c.Hops {
+			convertedHop := &Hop{
+				From:     conv.node(hop.From),
+				To:       conv.node(hop.To),
+				TestOnly: hop.TestOnly,
+				Blank:    hop.Blank,
+				Linkname: hop.Linkname,
+			}
+			for _, decl := range hop.Decls {
+				convertedHop.Decls = append(convertedHop.Decls, conv.decl(decl))
+			}
+			for _, prov := range hop.Provenance {
+				// imports of stub files are not converted
+				if imp, ok := conv.imps[prov.Import]; ok {
+					convertedHop.Imports = append(convertedHop.Imports, imp)
+				}
+			}
+			convertedCycle.Hops = append(convertedCycle.Hops, convertedHop)
+		}
+		converted = append(converted, convertedCycle)
+	}
+	return converted
+}
+
+func (conv *converter) node(node cycle.Node) Node {
+	switch node := node.(type) {
+	case *internal.Package:
+		return conv.pkgs[node]
+	case *internal.File:
+		return conv.files[node]
+	}
+	return nil
+}
+
+func comparePackages(a, b *Package) int {
+	if c := cmp.Compare(a.Dir, b.Dir); c != 0 {
+		return c
+	}
+	if a.IsExternalTest != b.IsExternalTest {
+		if a.IsExternalTest {
+			return 1
+		}
+		return -1
+	}
+	return cmp.Compare(a.Name, b.Name)
+}
+
+func sortedFiles(pkg *internal.Package) []*internal.File {
+	files := make([]*internal.File, 0, len(pkg.Files))
+	for _, file := range pkg.Files {
+		if file.IsStub {
+			continue
+		}
+		files = append(files, file)
+	}
+	slices.SortFunc(files, func(a, b *internal.File) int {
+		return cmp.Compare(a.AbsPath, b.AbsPath)
+	})
+	return files
+}
+
+func sortedImports(file *internal.File) []*internal.Import {
+	imports := make([]*internal.Import, 0, len(file.Imports))
+	for _, imp := range file.Imports {
+		imports = append(imports, imp)
+	}
+	slices.SortFunc(imports, func(a, b *internal.Import) int {
+		if c := cmp.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UID(), b.UID())
+	})
+	return imports
+}
+
+func referencedDecls(imp *internal.Import) []*internal.Decl {
+	decls := make([]*internal.Decl, 0, len(imp.ReferencedTypes))
+	for _, decl := range imp.ReferencedTypes {
+		decls = append(decls, decl)
+	}
+	return sortedDecls(decls)
+}
+
+func sortedUses(imp *internal.Import) []*internal.Use {
+	uses := slices.Clone(imp.Uses)
+	slices.SortStableFunc(uses, func(a, b *internal.Use) int {
+		if c := cmp.Compare(a.Pos.Line, b.Pos.Line); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Pos.Column, b.Pos.Column)
+	})
+	return uses
+}
+
+func sortedDecls(decls []*internal.Decl) []*internal.Decl {
+	slices.SortFunc(decls, func(a, b *internal.Decl) int {
+		return cmp.Compare(a.QualifiedName(), b.QualifiedName())
+	})
+	return decls
+}
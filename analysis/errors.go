@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+
+	"github.com/samlitowitz/goimportcycle/internal/analyzer"
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+)
+
+// ParseError is an error reading a directory, evaluating the build
+// constraints of a file, or parsing a file. Only the Filename of Pos is
+// known for directories and build constraints.
+type ParseError struct {
+	Pos token.Position
+	Err error
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Pos, err.Err)
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// NodeError is an error adding a declaration, or a reference to one, to the
+// import graph. Only the Filename of Pos is known for packages.
+type NodeError struct {
+	Pos token.Position
+	Err error
+}
+
+func (err *NodeError) Error() string {
+	if err.Pos.Filename == "" && !err.Pos.IsValid() {
+		return err.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", err.Pos, err.Err)
+}
+
+func (err *NodeError) Unwrap() error {
+	return err.Err
+}
+
+// ConflictError is an error for options which cannot be used together, e.g.
+// LowMemory and Typecheck, named as in the configuration file.
+type ConflictError struct {
+	Option string
+	With   string
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("%s cannot be used with %s", err.Option, err.With)
+}
+
+// convertError converts the errors of the internal packages to the errors of
+// this package, other errors are returned as is.
+func convertError(err error) error {
+	var parseErr *analyzer.ParseError
+	var nodeErr *internalAST.NodeError
+	var conflictErr *analyzer.ConflictError
+	switch {
+	case errors.As(err, &parseErr):
+		return &ParseError{Pos: parseErr.Pos, Err: parseErr.Err}
+	case errors.As(err, &nodeErr):
+		return &NodeError{Pos: nodeErr.Pos, Err: nodeErr.Err}
+	case errors.As(err, &conflictErr):
+		return &ConflictError{Option: conflictErr.Option, With: conflictErr.With}
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package analysis
+
+import "go/token"
+
+// Package is an analyzed package, files of the same directory with a
+// different package name, e.g. several main packages or an external _test
+// package, are separate packages.
+type Package struct {
+	// ImportPath is the import path of the directory, suffixed with _test
+	// for external test packages.
+	ImportPath string
+	Name       string
+	// Dir is the absolute path of the directory of the package.
+	Dir string
+	// Path is the module relative path of the package, suffixed with the
+	// package name for main packages and with _test for external test
+	// packages.
+	Path string
+
+	IsExternalTest bool
+	// OutOfScope is set when the package is not matched by
+	// Options.Patterns and is only analyzed as it is imported by a package
+	// which is.
+	OutOfScope    bool
+	InImportCycle bool
+
+	Files []*File
+}
+
+func (pkg *Package) ModuleRelativePath() string {
+	return pkg.Path
+}
+
+// File is an analyzed file.
+type File struct {
+	Package *Package
+
+	Name string
+	// AbsPath is the absolute path of the file.
+	AbsPath string
+	// Path is the module relative path of the file.
+	Path string
+
+	Test bool
+	// Cgo is set when the file imports the cgo pseudo-package "C".
+	Cgo           bool
+	InImportCycle bool
+
+	Imports []*Import
+	Decls   []*Decl
+}
+
+func (file *File) ModuleRelativePath() string {
+	return file.Path
+}
+
+// Import is an import of a file, or a //go:linkname dependency.
+type Import struct {
+	File *File
+
+	Name string
+	Path string
+	// Pos is the position of the import spec, or of the //go:linkname
+	// directive.
+	Pos token.Position
+	// Package is the imported package, nil for packages which are not
+	// analyzed.
+	Package *Package
+
+	IsBlank    bool
+	IsDot      bool
+	IsLinkname bool
+
+	// Referenced are the declarations of the imported package referenced
+	// through the import.
+	Referenced []*Decl
+	// Uses are the use sites of the referenced declarations ordered by
+	// position.
+	Uses          []*Use
+	InImportCycle bool
+}
+
+// Use is a use site of a declaration referenced through an import.
+type Use struct {
+	Decl *Decl
+	Pos  token.Position
+}
+
+// Decl is a top level declaration, methods are named Receiver.Method.
+type Decl struct {
+	File *File
+
+	Name string
+	// Pos is the position of the name of the declaration, unknown for
+	// declarations of packages which are not analyzed.
+	Pos token.Position
+}
+
+// PackageEdge aggregates every import from one package to another.
+type PackageEdge struct {
+	From, To *Package
+
+	Imports       []*Import
+	InImportCycle bool
+}
+
+// FileEdge aggregates every reference from one file to the declarations of
+// another.
+type FileEdge struct {
+	From, To *File
+
+	Decls         []*Decl
+	InImportCycle bool
+}
+
+// Diagnostic is a directory, file, or declaration skipped with
+// Options.KeepGoing.
+type Diagnostic struct {
+	// Pos is the position of the error, only its Filename is known for
+	// directories and build constraints.
+	Pos token.Position
+	// Skipped is what was skipped, e.g. file or declaration.
+	Skipped string
+	// Err is the *ParseError or *NodeError it was skipped for.
+	Err error
+}
+
+// Node is a *Package in a package cycle or a *File in a file cycle.
+type Node interface {
+	ModuleRelativePath() string
+}
+
+// Cycle is an elementary import cycle, the To of the last hop is the From
+// of the first.
+type Cycle struct {
+	Hops []*Hop
+}
+
+// Hop is a single dependency in an import cycle.
+type Hop struct {
+	From, To Node
+
+	Decls []*Decl
+	// Imports are the imports making the hop.
+	Imports []*Import
+	// TestOnly is set when every reference is made from a _test.go file.
+	TestOnly bool
+	// Blank is set when every import is a blank import, Decls is empty.
+	Blank bool
+	// Linkname is set when a reference is made by a //go:linkname directive
+	// rather than an import.
+	Linkname bool
+}
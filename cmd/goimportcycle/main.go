@@ -3,134 +3,221 @@
 import (
 	"context"
 	"flag"
-	"go/ast"
-	"go/parser"
-	"go/token"
-	"io/fs"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
+	"time"
 
+	"github.com/samlitowitz/goimportcycle/internal/analyzer"
 	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/pkgpattern"
+	"github.com/samlitowitz/goimportcycle/internal/platform"
 
 	"github.com/samlitowitz/goimportcycle/internal/dot"
+	"github.com/samlitowitz/goimportcycle/internal/report"
+)
 
-	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+// lowMemoryGCPercent is the garbage collection target percentage with
+// config.LowMemory, unless GOGC is set.
+const lowMemoryGCPercent = 25
 
-	"github.com/samlitowitz/goimportcycle/internal/modfile"
-)
+// limitHeapGrowth collects garbage once the heap grows by a quarter instead
+// of doubling, trading CPU time for a lower peak, unless GOGC is set.
+func limitHeapGrowth() {
+	if os.Getenv("GOGC") != "" {
+		return
+	}
+	debug.SetGCPercent(lowMemoryGCPercent)
+}
+
+// analyze analyzes module for the platform p, logging the warnings and
+// diagnostics of the analysis.
+func analyze(
+	cfg *config.Config,
+	module *analyzer.Module,
+	patterns *pkgpattern.Set,
+	p platform.Platform,
+) *analyzer.Result {
+	result, err := analyzer.Analyze(context.Background(), cfg, module, patterns, p)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logResult(module, result)
+	return result
+}
+
+// logResult logs the warnings and diagnostics of result.
+func logResult(module *analyzer.Module, result *analyzer.Result) {
+	for _, warning := range result.Warnings {
+		log.Printf("warning: %s", warning)
+	}
+	for _, diagnostic := range result.Diagnostics {
+		log.Printf("warning: %s", diagnostic.ModuleRelativeString(module.RootDir))
+	}
+}
 
-func walkDirectories(path string, errChan chan<- error) <-chan string {
-	dirOut := make(chan string)
-
-	go func() {
-		defer close(dirOut)
-		err := filepath.WalkDir(
-			path,
-			func(path string, d fs.DirEntry, err error) error {
-				if err != nil {
-					return err
-				}
-				if !d.IsDir() {
-					return nil
-				}
-				if strings.HasPrefix(d.Name(), ".") {
-					return fs.SkipDir
-				}
-				if strings.HasPrefix(d.Name(), "_") {
-					return fs.SkipDir
-				}
-				path, err = filepath.Abs(path)
-				if err != nil {
-					return err
-				}
-				dirOut <- path
-				return nil
-			},
-		)
+func writeReports(
+	cfg *config.Config,
+	module *analyzer.Module,
+	result *analyzer.Result,
+	dotFile, cyclesFile, suggestFile string,
+) error {
+	if cyclesFile != "" {
+		err := writeCycles(cfg, module, result, cyclesFile)
 		if err != nil {
-			errChan <- err
+			return err
 		}
-	}()
+	}
+	if suggestFile != "" {
+		err := writeSuggestions(cfg, module, result, suggestFile)
+		if err != nil {
+			return err
+		}
+	}
 
-	return dirOut
+	// the text reports replace the DOT output on stdout
+	if dotFile == "" && (cyclesFile == "-" || suggestFile == "-") {
+		return nil
+	}
+	return writeDot(cfg, module, result, dotFile)
 }
 
-func parseFiles(
-	dirOut <-chan string,
-	errChan chan<- error,
-	done <-chan struct{},
-) <-chan ast.Node {
-	depVis, nodeOut := internalAST.NewDependencyVisitor()
-
-	go func() {
-		for {
-			select {
-			case dirPath, ok := <-dirOut:
-				if !ok {
-					depVis.Close()
-					return
-				}
-				fset := token.NewFileSet()
-				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
-				if err != nil {
-					errChan <- err
-				}
-
-				for _, pkg := range pkgs {
-					ast.Walk(depVis, pkg)
-				}
-
-			case <-done:
-				depVis.Close()
-				return
-			}
+// writeCycles writes the import cycle report, followed by the diagnostics, to
+// cyclesFile.
+func writeCycles(
+	cfg *config.Config,
+	module *analyzer.Module,
+	result *analyzer.Result,
+	cyclesFile string,
+) error {
+	return streamOutput(cyclesFile, func(w io.Writer) error {
+		err := report.WriteCycles(w, cfg, module.Path, result.Packages)
+		if err != nil {
+			return err
 		}
-	}()
-	return nodeOut
+		// what was skipped with -keep-going follows the cycles
+		diagnostics := report.MarshalDiagnostics(module.Path, module.RootDir, result.Diagnostics)
+		if len(diagnostics) == 0 {
+			return nil
+		}
+		_, err = w.Write(append([]byte{'\n'}, diagnostics...))
+		return err
+	})
 }
 
-func detectInputCycles(
-	builder *internalAST.PrimitiveBuilder,
-	cancel context.CancelFunc,
-	nodeOut <-chan ast.Node,
-	errChan chan<- error,
-	done <-chan struct{},
+// writeSuggestions writes the cycle-breaking suggestions to suggestFile.
+func writeSuggestions(
+	cfg *config.Config,
+	module *analyzer.Module,
+	result *analyzer.Result,
+	suggestFile string,
 ) error {
-	go func() {
-		for {
-			select {
-			case node, ok := <-nodeOut:
-				if !ok {
-					cancel()
-					return
-				}
-				err := builder.AddNode(node)
-				if err != nil {
-					errChan <- err
-					return
-				}
-
-			case <-done:
-				return
-			}
+	return streamOutput(suggestFile, func(w io.Writer) error {
+		return report.WriteSuggestions(w, cfg, module.Path, result.Packages)
+	})
+}
+
+// writeDot writes the DOT output to dotFile.
+func writeDot(
+	cfg *config.Config,
+	module *analyzer.Module,
+	result *analyzer.Result,
+	dotFile string,
+) error {
+	return streamOutput(dotFile, func(w io.Writer) error {
+		return dot.Write(w, cfg, module.Path, result.Packages)
+	})
+}
+
+// splitPatterns splits a comma separated list of glob patterns.
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
 		}
-	}()
-	<-done
-	return builder.MarkupImportCycles()
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// platformOutputPath inserts the platform before the extension of an output
+// file, e.g. imports.dot becomes imports.linux_amd64.dot.
+func platformOutputPath(path string, p platform.Platform) string {
+	if path == "" || path == "-" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + p.GOOS + "_" + p.GOARCH + ext
+}
+
+// streamOutput streams the output written by write to path, stdout when path
+// is empty or -, so large outputs are never held in memory as a whole.
+func streamOutput(path string, write func(w io.Writer) error) error {
+	if path == "" || path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeOutput(path string, output []byte) error {
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(output)
+		return err
+	}
+	return os.WriteFile(path, output, 0644)
 }
 
 func main() {
-	var configFile, dotFile, path, resolution string
-	var debug bool
+	var configFile, dotFile, cyclesFile, suggestFile, path, resolution, edges string
+	var buildTags, goos, goarch, platforms string
+	var include, exclude, binaryDir string
+	var maxCycles int
+	var watchInterval time.Duration
+	var tests, typeCheck, gitIgnore, keepGoing, noCache, lowMemory, watchMode, debug bool
 	flag.StringVar(&configFile, "config", "", "Config file")
 	flag.StringVar(&dotFile, "dot", "", "DOT file for output")
+	flag.StringVar(&cyclesFile, "cycles", "", "Text file for the import cycle report, '-' for stdout")
+	flag.StringVar(&suggestFile, "suggest", "", "Text file for the imports to remove to break every import cycle, '-' for stdout")
+	flag.IntVar(&maxCycles, "max-cycles", config.DefaultMaxCycles, "Maximum number of import cycles to report, 0 for no limit")
 	flag.StringVar(&path, "path", "./", "Files to process")
 	flag.StringVar(&resolution, "resolution", "file", "Resolution, 'file' or 'package'")
+	flag.StringVar(&edges, "edges", "declarations", "Imports which are edges, 'declarations' for imports with a referenced declaration or 'imports' for every import like the compiler")
+	flag.StringVar(&buildTags, "tags", "", "Comma separated list of build tags, defaults to -tags in $GOFLAGS")
+	flag.StringVar(&goos, "goos", "", "Target operating system, defaults to $GOOS or the host operating system")
+	flag.StringVar(&goarch, "goarch", "", "Target architecture, defaults to $GOARCH or the host architecture")
+	flag.StringVar(&platforms, "platforms", "", "Comma separated list of GOOS/GOARCH platforms to analyze, reports which edges and cycles exist on which platform")
+	flag.StringVar(&include, "include", "", "Comma separated list of glob patterns of the directories to analyze, with everything below them, relative to the module root")
+	flag.StringVar(&exclude, "exclude", "", "Comma separated list of glob patterns of the directories to skip, relative to the module root")
+	flag.StringVar(&binaryDir, "binary", "", "Directory of a main package, e.g. cmd/api, limits the output to the packages and files reachable from it")
+	flag.BoolVar(&gitIgnore, "gitignore", false, "Skip directories ignored by .gitignore files")
+	flag.BoolVar(&tests, "tests", false, "Include _test.go files and external _test packages")
+	flag.BoolVar(&typeCheck, "typecheck", false, "Resolve references with go/types, attributes method calls, promoted fields, and aliases to the file declaring them")
+	flag.BoolVar(&keepGoing, "keep-going", false, "Skip directories, files, and declarations which cannot be analyzed instead of stopping, listing them as diagnostics")
+	flag.BoolVar(&noCache, "no-cache", false, "Parse every file instead of reading the dependency facts of unchanged files from the cache")
+	flag.BoolVar(&lowMemory, "low-memory", false, "Drop the syntax tree of each file right after extracting its dependency facts, bounding memory use on very large modules")
+	flag.BoolVar(&watchMode, "watch", false, "Analyze again whenever the sources change, rewriting -dot, -cycles, and -suggest and printing the import cycles which appeared or disappeared")
+	flag.DurationVar(&watchInterval, "watch-interval", time.Second, "Interval between checks for changed sources with -watch")
 	flag.BoolVar(&debug, "debug", false, "Emit debug output")
 	flag.Parse()
 
+	setFlags := make(map[string]struct{})
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = struct{}{}
+	})
+
 	var err error
 	cfg := config.Default()
 	if configFile != "" {
@@ -146,77 +233,167 @@ func main() {
 	if debug {
 		cfg.Debug.SetOutput(os.Stdout)
 	}
+	if _, ok := setFlags["max-cycles"]; ok {
+		cfg.MaxCycles = maxCycles
+	}
+	if _, ok := setFlags["tests"]; ok {
+		cfg.Tests = tests
+	}
+	if _, ok := setFlags["typecheck"]; ok {
+		cfg.Typecheck = typeCheck
+	}
+	if _, ok := setFlags["include"]; ok {
+		cfg.Include = splitPatterns(include)
+	}
+	if _, ok := setFlags["exclude"]; ok {
+		cfg.Exclude = splitPatterns(exclude)
+	}
+	if _, ok := setFlags["gitignore"]; ok {
+		cfg.GitIgnore = gitIgnore
+	}
+	if _, ok := setFlags["binary"]; ok {
+		cfg.Binary = binaryDir
+	}
+	if _, ok := setFlags["keep-going"]; ok {
+		cfg.KeepGoing = keepGoing
+	}
+	if _, ok := setFlags["no-cache"]; ok {
+		cfg.NoCache = noCache
+	}
+	if _, ok := setFlags["low-memory"]; ok {
+		cfg.LowMemory = lowMemory
+	}
+	if cfg.LowMemory {
+		limitHeapGrowth()
+	}
 
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	goModFile, err := modfile.FindGoModFile(absPath)
+	module, err := analyzer.FindModule(absPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	cfg.Debug.Printf("go.mod file: %s", goModFile)
+	cfg.Debug.Printf("go.mod file: %s", module.GoModFile)
+	cfg.Debug.Printf("Module Path: %s", module.Path)
+	cfg.Debug.Printf("Module Root Directory: %s", module.RootDir)
 
-	modulePath, err := modfile.GetModulePath(goModFile)
-	if err != nil {
-		log.Fatal(err)
+	var patterns *pkgpattern.Set
+	if flag.NArg() > 0 {
+		// relative patterns are relative to -path
+		patterns, err = pkgpattern.Parse(module.Path, module.RootDir, absPath, flag.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Debug.Printf("Package Patterns: %v", flag.Args())
 	}
-	moduleRootDir := filepath.Dir(goModFile)
-	cfg.Debug.Printf("Module Path: %s", modulePath)
-	cfg.Debug.Printf("Module Root Directory: %s", moduleRootDir)
 
 	switch resolution {
 	case "file":
 		cfg.Resolution = config.FileResolution
-		if err != nil {
-			log.Fatal(err)
-		}
 	case "package":
 		cfg.Resolution = config.PackageResolution
-		if err != nil {
-			log.Fatal(err)
-		}
 	default:
 		log.Fatal("resolution must be 'file' or 'package'")
 	}
 
-	builder := internalAST.NewPrimitiveBuilder(modulePath, moduleRootDir)
-	ctx, cancel := context.WithCancel(context.Background())
-	errChan := make(chan error)
+	if _, ok := setFlags["edges"]; ok {
+		switch edges {
+		case "declarations":
+			cfg.Edges = config.DeclarationEdges
+		case "imports":
+			cfg.Edges = config.ImportEdges
+		default:
+			log.Fatal("edges must be 'declarations' or 'imports'")
+		}
+	}
+
+	tags := platform.Default().Tags
+	if _, ok := setFlags["tags"]; ok {
+		tags = platform.ParseTags(buildTags)
+	}
 
-	go func() {
-		for {
-			select {
-			case err := <-errChan:
-				cancel()
+	if watchMode && platforms != "" {
+		log.Fatal("-watch cannot be used with -platforms")
+	}
+	if watchMode && (dotFile == "-" || cyclesFile == "-" || suggestFile == "-") {
+		log.Fatal("-watch writes the import cycle changes to stdout, -dot, -cycles, and -suggest must be files")
+	}
+	if watchInterval <= 0 {
+		log.Fatal("-watch-interval must be positive")
+	}
+
+	if platforms == "" {
+		p := platform.Default()
+		p.Tags = tags
+		if goos != "" {
+			p.GOOS = goos
+		}
+		if goarch != "" {
+			p.GOARCH = goarch
+		}
+		cfg.Debug.Printf("Platform: %s %v", p.String(), p.Tags)
+
+		if watchMode {
+			err = watchModule(cfg, module, patterns, p, watchInterval, dotFile, cyclesFile, suggestFile)
+			if err != nil {
 				log.Fatal(err)
-			case <-ctx.Done():
-				return
 			}
+			return
 		}
-	}()
 
-	dirOut := walkDirectories(moduleRootDir, errChan)
-	nodeOut := parseFiles(dirOut, errChan, ctx.Done())
-	err = detectInputCycles(builder, cancel, nodeOut, errChan, ctx.Done())
-	close(errChan)
-	if err != nil {
-		log.Fatal(err)
+		result := analyze(cfg, module, patterns, p)
+		err = writeReports(cfg, module, result, dotFile, cyclesFile, suggestFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	output, err := dot.Marshal(cfg, modulePath, builder.Packages())
+	if goos != "" || goarch != "" {
+		log.Fatal("-goos and -goarch cannot be used with -platforms")
+	}
+	matrix, err := platform.ParseList(platforms, tags)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if dotFile == "" {
-		_, err := os.Stdout.Write(output)
-		if err != nil {
-			log.Fatal(err)
+	results := make([]*report.PlatformPackages, 0, len(matrix))
+	for _, p := range matrix {
+		cfg.Debug.Printf("Platform: %s %v", p.String(), p.Tags)
+
+		result := analyze(cfg, module, patterns, p)
+
+		// stdout is reserved for the matrix report, only files are written
+		if platformCyclesFile := platformOutputPath(cyclesFile, p); platformCyclesFile != "" {
+			err = writeCycles(cfg, module, result, platformCyclesFile)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
-		return
+		if platformSuggestFile := platformOutputPath(suggestFile, p); platformSuggestFile != "" {
+			err = writeSuggestions(cfg, module, result, platformSuggestFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if platformDotFile := platformOutputPath(dotFile, p); platformDotFile != "" {
+			err = writeDot(cfg, module, result, platformDotFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		results = append(results, &report.PlatformPackages{
+			Platform: p.String(),
+			Packages: result.Packages,
+		})
+	}
+	output, err := report.MarshalMatrix(cfg, module.Path, results)
+	if err != nil {
+		log.Fatal(err)
 	}
-	err = os.WriteFile(dotFile, output, 0644)
+	err = writeOutput("-", output)
 	if err != nil {
 		log.Fatal(err)
 	}
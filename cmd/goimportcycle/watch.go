@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/samlitowitz/goimportcycle/internal/analyzer"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/pkgpattern"
+	"github.com/samlitowitz/goimportcycle/internal/platform"
+	"github.com/samlitowitz/goimportcycle/internal/report"
+	"github.com/samlitowitz/goimportcycle/internal/watch"
+)
+
+// watchModule analyzes module for the platform p whenever its sources change,
+// until interrupted. The dotFile, cyclesFile, and suggestFile which are set
+// are rewritten and the import cycles which appeared or disappeared are
+// printed. Only the changed directories are parsed again, see
+// analyzer.Session.
+func watchModule(
+	cfg *config.Config,
+	module *analyzer.Module,
+	patterns *pkgpattern.Set,
+	p platform.Platform,
+	interval time.Duration,
+	dotFile, cyclesFile, suggestFile string,
+) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	session := analyzer.NewSession(cfg, module, patterns, p)
+	take := func(ctx context.Context) (watch.Snapshot, error) {
+		return watch.Take(ctx, cfg, module.RootDir)
+	}
+	var cycles []string
+	err := watch.Poll(ctx, interval, take, func(dirs []string) {
+		if len(dirs) > 0 {
+			log.Printf("changed: %s", moduleRelativeDirs(module.RootDir, dirs))
+		}
+		result, err := session.Analyze(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Print(err)
+			}
+			return
+		}
+		logResult(module, result)
+
+		if cyclesFile != "" {
+			if err := writeCycles(cfg, module, result, cyclesFile); err != nil {
+				log.Print(err)
+			}
+		}
+		if suggestFile != "" {
+			if err := writeSuggestions(cfg, module, result, suggestFile); err != nil {
+				log.Print(err)
+			}
+		}
+		if dotFile != "" {
+			if err := writeDot(cfg, module, result, dotFile); err != nil {
+				log.Print(err)
+			}
+		}
+
+		current, limitReached, err := report.CyclePaths(cfg, result.Packages)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		if limitReached {
+			log.Printf("warning: only the first %d import cycle(s) are compared", cfg.MaxCycles)
+		}
+		output, err := report.MarshalCycleChanges(cfg, module.Path, cycles, current)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		if err := writeOutput("-", output); err != nil {
+			log.Print(err)
+		}
+		cycles = current
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// moduleRelativeDirs lists dirs relative to the module root directory.
+func moduleRelativeDirs(rootDir string, dirs []string) string {
+	relative := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(rootDir, dir)
+		if err != nil {
+			rel = dir
+		}
+		relative = append(relative, filepath.ToSlash(rel))
+	}
+	return strings.Join(relative, ", ")
+}
@@ -0,0 +1,17 @@
+package a
+
+import (
+	"log"
+
+	"github.com/samlitowitz/goimportcycle/examples/generics/b"
+)
+
+func (l *List[T]) Push(v T) {
+	log.Println("A")
+	l.items = append(l.items, v)
+	b.Fn()
+}
+
+type List[T any] struct {
+	items []T
+}
@@ -0,0 +1,6 @@
+package a
+
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
@@ -0,0 +1,3 @@
+package a
+
+type Set[T comparable] map[T]struct{}
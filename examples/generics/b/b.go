@@ -0,0 +1,14 @@
+package b
+
+import (
+	"log"
+
+	"github.com/samlitowitz/goimportcycle/examples/generics/a"
+)
+
+func Fn() {
+	_ = &a.List[int]{}
+	_ = a.Set[int]{}
+	_ = a.Pair[string, int]{}
+	log.Println("B")
+}
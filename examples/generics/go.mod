@@ -0,0 +1,3 @@
+module github.com/samlitowitz/goimportcycle/examples/generics
+
+go 1.21.5
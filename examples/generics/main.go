@@ -0,0 +1,8 @@
+package main
+
+import "github.com/samlitowitz/goimportcycle/examples/generics/a"
+
+func main() {
+	l := &a.List[string]{}
+	l.Push("A")
+}
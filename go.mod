@@ -1,6 +1,6 @@
 module github.com/samlitowitz/goimportcycle
 
-go 1.18
+go 1.22
 
 require (
 	golang.org/x/mod v0.14.0
@@ -9,4 +9,4 @@ require (
 
 require github.com/go-playground/colors v1.3.1
 
-require github.com/google/go-cmp v0.6.0 // indirect
+require github.com/google/go-cmp v0.6.0
@@ -0,0 +1,908 @@
+package analyzer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/binary"
+	"github.com/samlitowitz/goimportcycle/internal/cache"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/directory"
+	"github.com/samlitowitz/goimportcycle/internal/modfile"
+	"github.com/samlitowitz/goimportcycle/internal/pkgname"
+	"github.com/samlitowitz/goimportcycle/internal/pkgpattern"
+	"github.com/samlitowitz/goimportcycle/internal/platform"
+	"github.com/samlitowitz/goimportcycle/internal/typecheck"
+)
+
+// Module is a module located by its go.mod file.
+type Module struct {
+	GoModFile string
+	Path      string
+	RootDir   string
+}
+
+// FindModule finds the module containing the directory path.
+func FindModule(path string) (*Module, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	goModFile, err := modfile.FindGoModFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	modulePath, err := modfile.GetModulePath(goModFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Module{
+		GoModFile: goModFile,
+		Path:      modulePath,
+		RootDir:   filepath.Dir(goModFile),
+	}, nil
+}
+
+// Result holds the analyzed packages of a module for one platform.
+type Result struct {
+	// Packages are marked up with their import cycles, only the packages of
+	// the binary are kept when config.Binary is set.
+	Packages []*internal.Package
+	// Warnings did not stop the analysis but may make it incomplete.
+	Warnings []string
+	// Diagnostics are what was skipped with config.KeepGoing, ordered by
+	// position.
+	Diagnostics []*Diagnostic
+
+	mu sync.Mutex
+}
+
+// Diagnostic is a directory, file, or node skipped with config.KeepGoing.
+type Diagnostic struct {
+	Pos token.Position
+	// Skipped is what was skipped, e.g. file or declaration.
+	Skipped string
+	// Err is the *ParseError or *internalAST.NodeError it was skipped for.
+	Err error
+}
+
+// ModuleRelativeString describes the diagnostic with its position relative
+// to moduleRoot, e.g. a/a.go:3:1: skipped file: expected declaration.
+func (diagnostic *Diagnostic) ModuleRelativeString(moduleRoot string) string {
+	err := errors.Unwrap(diagnostic.Err)
+	if err == nil {
+		err = diagnostic.Err
+	}
+	desc := fmt.Sprintf("skipped %s: %s", diagnostic.Skipped, err)
+	if pos := internal.ModuleRelativePosition(moduleRoot, diagnostic.Pos); pos != "" {
+		return pos + ": " + desc
+	}
+	return desc
+}
+
+func (result *Result) warnf(format string, args ...any) {
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	result.Warnings = append(result.Warnings, fmt.Sprintf(format, args...))
+}
+
+// skip records err as a diagnostic with cfg.KeepGoing, otherwise err is
+// returned.
+func (result *Result) skip(cfg *config.Config, skipped string, err error) error {
+	if !cfg.KeepGoing {
+		return err
+	}
+	diagnostic := &Diagnostic{
+		Skipped: skipped,
+		Err:     err,
+	}
+	var parseErr *ParseError
+	var nodeErr *internalAST.NodeError
+	switch {
+	case errors.As(err, &parseErr):
+		diagnostic.Pos = parseErr.Pos
+	case errors.As(err, &nodeErr):
+		diagnostic.Pos = nodeErr.Pos
+	}
+
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	result.Diagnostics = append(result.Diagnostics, diagnostic)
+	return nil
+}
+
+func (result *Result) addDiagnostics(diagnostics []*Diagnostic) {
+	if len(diagnostics) == 0 {
+		return
+	}
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	result.Diagnostics = append(result.Diagnostics, diagnostics...)
+}
+
+// sortDiagnostics orders the diagnostics by position, they are recorded in
+// the order the directories are parsed in.
+func (result *Result) sortDiagnostics() {
+	slices.SortStableFunc(result.Diagnostics, func(a, b *Diagnostic) int {
+		if c := cmp.Compare(a.Pos.Filename, b.Pos.Filename); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Pos.Line, b.Pos.Line); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Pos.Column, b.Pos.Column)
+	})
+}
+
+// Analyze walks, parses, and marks up the import cycles of the packages of
+// module selected by cfg and patterns, every package when patterns is nil,
+// for the platform p. Analysis stops with the error of ctx once it is done.
+func Analyze(
+	ctx context.Context,
+	cfg *config.Config,
+	module *Module,
+	patterns *pkgpattern.Set,
+	p platform.Platform,
+) (*Result, error) {
+	return analyze(ctx, cfg, module, patterns, p, nil)
+}
+
+// analyze is Analyze reusing the unchanged directories of session, unless it
+// is nil.
+func analyze(
+	ctx context.Context,
+	cfg *config.Config,
+	module *Module,
+	patterns *pkgpattern.Set,
+	p platform.Platform,
+	session *Session,
+) (*Result, error) {
+	// type checking needs the syntax trees of the whole module
+	if cfg.LowMemory && cfg.Typecheck {
+		return nil, &ConflictError{Option: "lowMemory", With: "typecheck"}
+	}
+	result := &Result{}
+	var pkgs []*internal.Package
+	var err error
+	if cfg.Typecheck {
+		pkgs, err = analyzeTypeChecked(ctx, cfg, module, patterns, p, result)
+	} else {
+		pkgs, err = analyzeStreaming(ctx, cfg, module, patterns, p, result, session)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.sortDiagnostics()
+	markOutOfScope(patterns, pkgs)
+
+	pkgs, err = binaryPackages(cfg, module.RootDir, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	result.Packages = pkgs
+	return result, nil
+}
+
+// pipeline runs the stages of an analysis, keeping the first error of any
+// stage.
+type pipeline struct {
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+func newPipeline(parentCtx context.Context) *pipeline {
+	ctx, cancel := context.WithCancel(parentCtx)
+	return &pipeline{
+		parent: parentCtx,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// goStage runs stage in its own goroutine, an error cancels every stage.
+func (pl *pipeline) goStage(stage func() error) {
+	pl.wg.Add(1)
+	go func() {
+		defer pl.wg.Done()
+		if err := stage(); err != nil {
+			pl.fail(err)
+		}
+	}()
+}
+
+func (pl *pipeline) fail(err error) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.err == nil {
+		pl.err = err
+	}
+	pl.cancel()
+}
+
+// wait waits for every stage and returns the first error, the error of the
+// parent context if it is done.
+func (pl *pipeline) wait() error {
+	pl.wg.Wait()
+	pl.cancel()
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.err != nil {
+		return pl.err
+	}
+	return pl.parent.Err()
+}
+
+func (pl *pipeline) walkDirectories(cfg *config.Config, path string) <-chan string {
+	emitter, dirOut := directory.NewEmitter()
+	emitter.SetGitIgnore(cfg.GitIgnore)
+
+	pl.goStage(func() error {
+		defer emitter.Close()
+		err := emitter.SetInclude(cfg.Include)
+		if err != nil {
+			return err
+		}
+		err = emitter.SetExclude(cfg.Exclude)
+		if err != nil {
+			return err
+		}
+		return emitter.Walk(pl.ctx, path)
+	})
+
+	return dirOut
+}
+
+// scopedDirectories emits the directories of the packages matched by
+// patterns and the in-module packages they transitively import, every
+// directory of the module when patterns is nil.
+func (pl *pipeline) scopedDirectories(
+	cfg *config.Config,
+	patterns *pkgpattern.Set,
+	buildContext *build.Context,
+	moduleRootDir string,
+	result *Result,
+) <-chan string {
+	dirs := pl.walkDirectories(cfg, moduleRootDir)
+	if patterns == nil {
+		return dirs
+	}
+	dirOut := make(chan string)
+	pl.goStage(func() error {
+		defer close(dirOut)
+		var all []string
+		for dirPath := range dirs {
+			all = append(all, dirPath)
+		}
+		if err := pl.ctx.Err(); err != nil {
+			return err
+		}
+		closure, err := patterns.Closure(all, buildContext, cfg.Tests, func(err error) error {
+			var importErr *pkgpattern.ImportError
+			if !errors.As(err, &importErr) {
+				return err
+			}
+			return result.skip(cfg, "directory", &ParseError{
+				Pos: token.Position{Filename: importErr.Dir},
+				Err: importErr.Err,
+			})
+		})
+		if err != nil {
+			return err
+		}
+		for _, pattern := range patterns.Unmatched(closure) {
+			result.warnf("pattern %s: matched no packages", pattern)
+		}
+		for _, dirPath := range closure {
+			select {
+			case dirOut <- dirPath:
+			case <-pl.ctx.Done():
+				return pl.ctx.Err()
+			}
+		}
+		return nil
+	})
+	return dirOut
+}
+
+// parsedDir is a parsed directory and the nodes extracted from its
+// packages.
+type parsedDir struct {
+	path  string
+	pkgs  map[string]*ast.Package
+	nodes []ast.Node
+}
+
+// parseDirs parses the directories of dirOut with parse and a worker per
+// CPU. Directories are emitted in the order they are received whichever
+// worker finishes first, so the builder receives the same nodes in the same
+// order as it would from a single worker.
+func (pl *pipeline) parseDirs(
+	dirOut <-chan string,
+	parse func(dirPath string) (*parsedDir, error),
+) <-chan *parsedDir {
+	type job struct {
+		path string
+		out  chan<- *parsedDir
+	}
+	workers := runtime.GOMAXPROCS(0)
+	jobs := make(chan job)
+	// pending holds the output of every directory in the order received,
+	// it bounds how far the workers run ahead of the builder
+	pending := make(chan chan *parsedDir, workers)
+
+	pl.goStage(func() error {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			select {
+			case dirPath, ok := <-dirOut:
+				if !ok {
+					return nil
+				}
+				out := make(chan *parsedDir, 1)
+				select {
+				case pending <- out:
+				case <-pl.ctx.Done():
+					return pl.ctx.Err()
+				}
+				select {
+				case jobs <- job{path: dirPath, out: out}:
+				case <-pl.ctx.Done():
+					return pl.ctx.Err()
+				}
+
+			case <-pl.ctx.Done():
+				return pl.ctx.Err()
+			}
+		}
+	})
+
+	for i := 0; i < workers; i++ {
+		pl.goStage(func() error {
+			for j := range jobs {
+				dir, err := parse(j.path)
+				if err != nil {
+					return err
+				}
+				j.out <- dir
+			}
+			return nil
+		})
+	}
+
+	parsedOut := make(chan *parsedDir)
+	pl.goStage(func() error {
+		defer close(parsedOut)
+		for out := range pending {
+			var dir *parsedDir
+			select {
+			case dir = <-out:
+			case <-pl.ctx.Done():
+				return pl.ctx.Err()
+			}
+			select {
+			case parsedOut <- dir:
+			case <-pl.ctx.Done():
+				return pl.ctx.Err()
+			}
+		}
+		return nil
+	})
+	return parsedOut
+}
+
+// parseDir parses the files in dirPath selected by the build context,
+// _test.go files are skipped unless cfg.Tests is set. A directory which
+// cannot be read, or a file which cannot be parsed or whose build
+// constraints cannot be evaluated, fails with a *ParseError unless
+// cfg.KeepGoing is set, in which case it is left out.
+func parseDir(
+	cfg *config.Config,
+	fset *token.FileSet,
+	dirPath string,
+	buildContext *build.Context,
+	result *Result,
+) (map[string]*ast.Package, error) {
+	names, err := goFiles(cfg, dirPath, result)
+	if err != nil || names == nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]*ast.Package)
+	for _, name := range names {
+		filename := filepath.Join(dirPath, name)
+		file, err := parseFile(fset, buildContext, dirPath, name, nil)
+		if err != nil {
+			err = result.skip(cfg, "file", err)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if file == nil {
+			continue
+		}
+		pkg, ok := pkgs[file.Name.Name]
+		if !ok {
+			pkg = &ast.Package{
+				Name:  file.Name.Name,
+				Files: make(map[string]*ast.File),
+			}
+			pkgs[file.Name.Name] = pkg
+		}
+		pkg.Files[filename] = file
+	}
+	return pkgs, nil
+}
+
+// goFiles returns the names of the .go files in dirPath, nil if the
+// directory is skipped with cfg.KeepGoing.
+func goFiles(cfg *config.Config, dirPath string, result *Result) ([]string, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, result.skip(cfg, "directory", &ParseError{
+			Pos: token.Position{Filename: dirPath},
+			Err: err,
+		})
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if !cfg.Tests && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// parseFile parses the file name in dirPath, read from src unless it is nil,
+// if it is selected by the build context, nil if it is not.
+func parseFile(
+	fset *token.FileSet,
+	buildContext *build.Context,
+	dirPath, name string,
+	src []byte,
+) (*ast.File, error) {
+	filename := filepath.Join(dirPath, name)
+	match, err := buildContext.MatchFile(dirPath, name)
+	if err != nil {
+		return nil, &ParseError{
+			Pos: token.Position{Filename: filename},
+			Err: err,
+		}
+	}
+	if !match {
+		return nil, nil
+	}
+	var file *ast.File
+	if src == nil {
+		file, err = parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	} else {
+		file, err = parser.ParseFile(fset, filename, src, parser.ParseComments)
+	}
+	if err != nil {
+		return nil, newParseError(filename, err)
+	}
+	return file, nil
+}
+
+// extractDir returns the nodes of the files in dirPath selected by the build
+// context, like parseDir followed by internalAST.Extract. The facts of
+// unchanged files are read from factCache instead of parsing them, every
+// file is parsed when it is nil.
+func extractDir(
+	cfg *config.Config,
+	factCache *cache.Cache,
+	fset *token.FileSet,
+	dirPath string,
+	buildContext *build.Context,
+	resolver internalAST.PackageNameResolver,
+	result *Result,
+) ([]ast.Node, error) {
+	names, err := goFiles(cfg, dirPath, result)
+	if err != nil || names == nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]*ast.Package)
+	nodesByFile := make(map[string][]ast.Node, len(names))
+	for _, name := range names {
+		filename := filepath.Join(dirPath, name)
+		nodes, err := extractFile(cfg, factCache, fset, buildContext, resolver, dirPath, name)
+		if err != nil {
+			err = result.skip(cfg, "file", err)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		file := nodes[0].(*internalAST.File)
+		pkg, ok := pkgs[file.Name.Name]
+		if !ok {
+			pkg = &ast.Package{
+				Name:  file.Name.Name,
+				Files: make(map[string]*ast.File),
+			}
+			pkgs[file.Name.Name] = pkg
+		}
+		pkg.Files[filename] = file.File
+		nodesByFile[filename] = nodes
+	}
+
+	// ordered the same as internalAST.Extract
+	var batch []ast.Node
+	for _, pkgName := range sortedKeys(pkgs) {
+		pkg := pkgs[pkgName]
+		if pkgNode := internalAST.NewPackage(fset, pkg); pkgNode != nil {
+			batch = append(batch, pkgNode)
+		}
+		for _, filename := range sortedKeys(pkg.Files) {
+			batch = append(batch, nodesByFile[filename]...)
+		}
+	}
+	return batch, nil
+}
+
+// extractFile returns the nodes of the file name in dirPath, none if it is
+// not selected by the build context. Cached facts are used when the file is
+// unchanged, otherwise the file is parsed and its facts are cached. With
+// config.LowMemory the nodes are decoded from the facts of the file even
+// without a cache, so its syntax tree is dropped right after parsing it.
+func extractFile(
+	cfg *config.Config,
+	factCache *cache.Cache,
+	fset *token.FileSet,
+	buildContext *build.Context,
+	resolver internalAST.PackageNameResolver,
+	dirPath, name string,
+) ([]ast.Node, error) {
+	filename := filepath.Join(dirPath, name)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, &ParseError{Pos: token.Position{Filename: filename}, Err: err}
+	}
+	if !cache.Cacheable(content) || (factCache == nil && !cfg.LowMemory) {
+		file, err := parseFile(fset, buildContext, dirPath, name, content)
+		if err != nil || file == nil {
+			return nil, err
+		}
+		return internalAST.ExtractFile(resolver, fset, filename, file), nil
+	}
+
+	var key cache.Key
+	var facts *cache.Facts
+	var ok bool
+	if factCache != nil {
+		key = factCache.Key(buildContext, filename, content)
+		facts, ok = factCache.Get(key, resolver)
+	}
+	if !ok {
+		// only the facts are kept, so the file is parsed with a file set of
+		// its own
+		parseFset := token.NewFileSet()
+		file, err := parseFile(parseFset, buildContext, dirPath, name, content)
+		if err != nil {
+			return nil, err
+		}
+		facts = &cache.Facts{}
+		if file != nil {
+			facts = cache.ExtractFile(resolver, parseFset, filename, file)
+		}
+		if factCache != nil {
+			// a failed write only costs parsing the file again
+			_ = factCache.Put(key, facts)
+		}
+	}
+	if !facts.Match {
+		return nil, nil
+	}
+	return facts.Decode(fset, filename, content), nil
+}
+
+// openCache opens the cache of cfg.CacheDir, nil with cfg.NoCache or if it
+// cannot be opened, in which case every file is parsed.
+func openCache(cfg *config.Config, result *Result) *cache.Cache {
+	if cfg.NoCache {
+		return nil
+	}
+	factCache, err := cache.Open(cfg.CacheDir)
+	if err != nil {
+		result.warnf("cache: %s, parsing every file", err)
+		return nil
+	}
+	return factCache
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// newParseError positions err, returned by parsing filename, at its first
+// syntax error.
+func newParseError(filename string, err error) *ParseError {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return &ParseError{Pos: token.Position{Filename: filename}, Err: err}
+	}
+	msg := list[0].Msg
+	if len(list) > 1 {
+		msg = fmt.Sprintf("%s (and %d more errors)", msg, len(list)-1)
+	}
+	return &ParseError{Pos: list[0].Pos, Err: errors.New(msg)}
+}
+
+// nodeAdder adds the nodes emitted by the DependencyVisitor to the builder.
+// With config.KeepGoing a node which cannot be added is skipped along with
+// the nodes of the package or file it starts.
+type nodeAdder struct {
+	cfg     *config.Config
+	builder *internalAST.PrimitiveBuilder
+	result  *Result
+
+	// skipping is the *internalAST.Package or *internalAST.File being
+	// skipped, nil if none is
+	skipping ast.Node
+}
+
+func (adder *nodeAdder) add(node ast.Node) error {
+	switch node.(type) {
+	case *internalAST.Package:
+		adder.skipping = nil
+	case *internalAST.File:
+		if _, ok := adder.skipping.(*internalAST.File); ok {
+			adder.skipping = nil
+		}
+	case *internalAST.Reference:
+		// references are added once every package is walked
+		adder.skipping = nil
+	}
+	if adder.skipping != nil {
+		return nil
+	}
+
+	err := adder.builder.AddNode(node)
+	if err == nil {
+		return nil
+	}
+	switch node.(type) {
+	case *internalAST.Package, *internalAST.File:
+		adder.skipping = node
+	}
+	return adder.result.skip(adder.cfg, skippedNode(node), err)
+}
+
+// skippedNode describes what is skipped with node.
+func skippedNode(node ast.Node) string {
+	switch node.(type) {
+	case *internalAST.Package:
+		return "package"
+	case *internalAST.File:
+		return "file"
+	case *internalAST.ImportSpec:
+		return "import"
+	case *internalAST.Linkname:
+		return "go:linkname directive"
+	case *internalAST.FuncDecl, *ast.GenDecl:
+		return "declaration"
+	default:
+		return "reference"
+	}
+}
+
+func analyzeStreaming(
+	ctx context.Context,
+	cfg *config.Config,
+	module *Module,
+	patterns *pkgpattern.Set,
+	p platform.Platform,
+	result *Result,
+	session *Session,
+) ([]*internal.Package, error) {
+	buildContext := p.Context()
+	resolver, err := pkgname.NewResolver(module.GoModFile, buildContext)
+	if err != nil {
+		return nil, err
+	}
+	factCache := openCache(cfg, result)
+	builder := internalAST.NewPrimitiveBuilder(module.Path, module.RootDir)
+	builder.SetEdges(cfg.Edges)
+	adder := &nodeAdder{cfg: cfg, builder: builder, result: result}
+
+	pl := newPipeline(ctx)
+	dirOut := pl.scopedDirectories(cfg, patterns, buildContext, module.RootDir, result)
+	parse := func(dirPath string) (*parsedDir, error) {
+		var nodes []ast.Node
+		var err error
+		if session != nil {
+			nodes, err = session.extractDir(factCache, dirPath, buildContext, resolver, result)
+		} else {
+			// every directory is positioned with its own file set
+			nodes, err = extractDir(cfg, factCache, token.NewFileSet(), dirPath, buildContext, resolver, result)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &parsedDir{path: dirPath, nodes: nodes}, nil
+	}
+	for dir := range pl.parseDirs(dirOut, parse) {
+		// keep draining so the workers can finish
+		if pl.ctx.Err() != nil {
+			continue
+		}
+		for _, node := range dir.nodes {
+			if err := adder.add(node); err != nil {
+				pl.fail(err)
+				break
+			}
+		}
+	}
+	if err := pl.wait(); err != nil {
+		return nil, err
+	}
+	if err := builder.MarkupImportCycles(); err != nil {
+		return nil, err
+	}
+
+	for _, diagnostic := range resolver.Diagnostics() {
+		result.warnf("%s", diagnostic)
+	}
+	return builder.Packages(), nil
+}
+
+// analyzeTypeChecked parses the whole module up front and type checks it, so
+// references are attributed to the file declaring the used object. Selector
+// expressions the type checker could not resolve are attributed by import
+// name as usual.
+func analyzeTypeChecked(
+	ctx context.Context,
+	cfg *config.Config,
+	module *Module,
+	patterns *pkgpattern.Set,
+	p platform.Platform,
+	result *Result,
+) ([]*internal.Package, error) {
+	buildContext := p.Context()
+	resolver, err := pkgname.NewResolver(module.GoModFile, buildContext)
+	if err != nil {
+		return nil, err
+	}
+
+	// the file set is shared as the module is type checked as a whole
+	fset := token.NewFileSet()
+	pl := newPipeline(ctx)
+	dirOut := pl.scopedDirectories(cfg, patterns, buildContext, module.RootDir, result)
+	var dirs []*parsedDir
+	parse := func(dirPath string) (*parsedDir, error) {
+		pkgs, err := parseDir(cfg, fset, dirPath, buildContext, result)
+		if err != nil {
+			return nil, err
+		}
+		return &parsedDir{
+			path:  dirPath,
+			pkgs:  pkgs,
+			nodes: internalAST.Extract(resolver, fset, pkgs),
+		}, nil
+	}
+	for dir := range pl.parseDirs(dirOut, parse) {
+		dirs = append(dirs, dir)
+	}
+	if err := pl.wait(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(dirs, func(a, b *parsedDir) int {
+		return cmp.Compare(a.path, b.path)
+	})
+	pkgsByDir := make(map[string]map[string]*ast.Package, len(dirs))
+	for _, dir := range dirs {
+		pkgsByDir[dir.path] = dir.pkgs
+	}
+
+	checked := typecheck.Check(fset, module.Path, module.RootDir, pkgsByDir, buildContext)
+	for _, typeErr := range checked.Errors {
+		cfg.Debug.Printf("type error: %s", typeErr)
+	}
+	if len(checked.Errors) > 0 {
+		result.warnf(
+			"type checking reported %d error(s), unresolved references are attributed by import name, use -debug to list them",
+			len(checked.Errors),
+		)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	builder := internalAST.NewPrimitiveBuilder(module.Path, module.RootDir)
+	builder.SetEdges(cfg.Edges)
+	adder := &nodeAdder{cfg: cfg, builder: builder, result: result}
+	for _, dir := range dirs {
+		for _, node := range dir.nodes {
+			switch node := node.(type) {
+			case *internalAST.SelectorExpr:
+				if checked.Resolved(node.Sel) {
+					continue
+				}
+			case *internalAST.Ident:
+				if checked.Resolved(node.Ident) {
+					continue
+				}
+			}
+			if err := adder.add(node); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, ref := range checked.References {
+		err = adder.add(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = builder.MarkupImportCycles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, diagnostic := range resolver.Diagnostics() {
+		result.warnf("%s", diagnostic)
+	}
+	return builder.Packages(), nil
+}
+
+// markOutOfScope marks the packages not matched by patterns, nothing when
+// patterns is nil.
+func markOutOfScope(patterns *pkgpattern.Set, pkgs []*internal.Package) {
+	if patterns == nil {
+		return
+	}
+	for _, pkg := range pkgs {
+		if pkg.IsStub {
+			continue
+		}
+		pkg.OutOfScope = !patterns.Match(pkg.DirName)
+	}
+}
+
+// binaryPackages returns the packages reachable from the main package of
+// cfg.Binary, every package when it is not set.
+func binaryPackages(cfg *config.Config, moduleRootDir string, pkgs []*internal.Package) ([]*internal.Package, error) {
+	if cfg.Binary == "" {
+		return pkgs, nil
+	}
+	dir := filepath.Join(moduleRootDir, filepath.FromSlash(cfg.Binary))
+	return binary.Reachable(pkgs, dir, cfg.Edges)
+}
@@ -0,0 +1,33 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/token"
+)
+
+// ParseError is an error reading a directory, evaluating the build
+// constraints of a file, or parsing a file. Only the Filename of Pos is
+// known for directories and build constraints.
+type ParseError struct {
+	Pos token.Position
+	Err error
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Pos, err.Err)
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// ConflictError is an analysis configured with options which cannot be used
+// together, named as in the configuration file.
+type ConflictError struct {
+	Option string
+	With   string
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("%s cannot be used with %s", err.Option, err.With)
+}
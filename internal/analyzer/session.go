@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"context"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"sync"
+
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/cache"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/directory"
+	"github.com/samlitowitz/goimportcycle/internal/pkgpattern"
+	"github.com/samlitowitz/goimportcycle/internal/platform"
+)
+
+// Session analyzes a module repeatedly, e.g. in watch mode. The nodes of a
+// directory are kept between analyses and reused while its .go files are
+// unchanged and the package names they import resolve the same, so only the
+// changed directories are parsed again. With config.Typecheck every
+// directory is parsed for every analysis. Analyses of a session must not run
+// concurrently.
+type Session struct {
+	cfg      *config.Config
+	module   *Module
+	patterns *pkgpattern.Set
+	platform platform.Platform
+
+	mu sync.Mutex
+	// dirs are the directories of the previous analysis, next those of the
+	// running analysis
+	dirs map[string]*sessionDir
+	next map[string]*sessionDir
+}
+
+// sessionDir is an extracted directory.
+type sessionDir struct {
+	// stamp identifies the .go files of the directory, see directory.Stamp
+	stamp        string
+	packageNames map[string]string
+	nodes        []ast.Node
+	diagnostics  []*Diagnostic
+}
+
+// NewSession returns a session analyzing the packages of module selected by
+// cfg and patterns, every package when patterns is nil, for the platform p.
+func NewSession(
+	cfg *config.Config,
+	module *Module,
+	patterns *pkgpattern.Set,
+	p platform.Platform,
+) *Session {
+	return &Session{
+		cfg:      cfg,
+		module:   module,
+		patterns: patterns,
+		platform: p,
+		dirs:     make(map[string]*sessionDir),
+	}
+}
+
+// Analyze walks, parses, and marks up the import cycles of the packages of
+// the session. Analysis stops with the error of ctx once it is done, the
+// directories of the previous analysis are kept for the next one then.
+func (s *Session) Analyze(ctx context.Context) (*Result, error) {
+	if s.cfg.Typecheck {
+		return analyze(ctx, s.cfg, s.module, s.patterns, s.platform, nil)
+	}
+	s.next = make(map[string]*sessionDir, len(s.dirs))
+	result, err := analyze(ctx, s.cfg, s.module, s.patterns, s.platform, s)
+	if err == nil {
+		s.dirs = s.next
+	}
+	s.next = nil
+	return result, err
+}
+
+// extractDir returns the nodes of the directory at dirPath, those of the
+// previous analysis if it is unchanged.
+func (s *Session) extractDir(
+	factCache *cache.Cache,
+	dirPath string,
+	buildContext *build.Context,
+	resolver internalAST.PackageNameResolver,
+	result *Result,
+) ([]ast.Node, error) {
+	// stamped before extracting so a file changed meanwhile is extracted
+	// again by the next analysis
+	stamp := directory.Stamp(dirPath, directory.IsGoFile)
+	s.mu.Lock()
+	prev := s.dirs[dirPath]
+	s.mu.Unlock()
+	if prev != nil && stamp != "" && prev.stamp == stamp && internalAST.Resolves(resolver, prev.packageNames) {
+		result.addDiagnostics(prev.diagnostics)
+		s.keep(dirPath, prev)
+		return prev.nodes, nil
+	}
+
+	recorder := internalAST.NewRecordingResolver(resolver)
+	// the diagnostics of the directory are kept with its nodes
+	dirResult := &Result{}
+	nodes, err := extractDir(s.cfg, factCache, token.NewFileSet(), dirPath, buildContext, recorder, dirResult)
+	if err != nil {
+		return nil, err
+	}
+	result.addDiagnostics(dirResult.Diagnostics)
+	s.keep(dirPath, &sessionDir{
+		stamp:        stamp,
+		packageNames: recorder.Names(),
+		nodes:        nodes,
+		diagnostics:  dirResult.Diagnostics,
+	})
+	return nodes, nil
+}
+
+// keep keeps dir for the next analysis.
+func (s *Session) keep(dirPath string, dir *sessionDir) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next[dirPath] = dir
+}
@@ -3,14 +3,23 @@
 import (
 	"go/ast"
 	"go/token"
+	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+
+	"github.com/samlitowitz/goimportcycle/internal/pkgname"
 )
 
 type Package struct {
 	*ast.Package
 
-	DirName string
+	DirName        string
+	IsExternalTest bool
+	// FileSet positions the nodes of the package, nil if positions are not
+	// known.
+	FileSet *token.FileSet
 }
 
 type File struct {
@@ -40,38 +49,256 @@ type SelectorExpr struct {
 	ImportName string
 }
 
+// Ident is an unqualified identifier in a file with dot-imports, it
+// references a declaration of a dot-imported package unless it is declared
+// by the package itself.
+type Ident struct {
+	*ast.Ident
+}
+
+// Linkname is a //go:linkname directive binding a local declaration to the
+// declaration Name of the package ImportPath, a dependency without an import.
+type Linkname struct {
+	*ast.Comment
+
+	LocalName   string
+	ImportPath  string
+	PackageName string
+	// Name is the qualified name of the declaration, e.g. T.M for the
+	// method (*T).M.
+	Name string
+}
+
+// Reference is a use of a declaration in another package resolved by the
+// type checker. The identifier is the use site.
+type Reference struct {
+	*ast.Ident
+
+	// AbsPath is the file the reference is made from.
+	AbsPath string
+	// ImportPath is the import the reference is made through.
+	ImportPath string
+	// DeclAbsPath is the file the referenced declaration is declared in.
+	DeclAbsPath string
+	// DeclName is the qualified name of the referenced declaration.
+	DeclName string
+	// Position is the position of the use site.
+	Position token.Position
+}
+
 func (decl FuncDecl) IsReceiver() bool {
 	return decl.Recv != nil
 }
 
-type DependencyVisitor struct {
-	out chan<- ast.Node
+// PackageNameResolver resolves the name declared by the package clause of an
+// imported package.
+type PackageNameResolver interface {
+	PackageName(importPath string) string
+}
+
+// RecordingResolver records the names resolved by a PackageNameResolver, so
+// nodes extracted with it can be reused while every name resolves the same.
+type RecordingResolver struct {
+	resolver PackageNameResolver
+
+	mu    sync.Mutex
+	names map[string]string
+}
+
+func NewRecordingResolver(resolver PackageNameResolver) *RecordingResolver {
+	return &RecordingResolver{
+		resolver: resolver,
+		names:    make(map[string]string),
+	}
+}
+
+func (r *RecordingResolver) PackageName(importPath string) string {
+	name := r.resolver.PackageName(importPath)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names[importPath] = name
+	return name
+}
+
+// Names returns the resolved names by import path.
+func (r *RecordingResolver) Names() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.names)
+}
+
+// Resolves reports whether every name in names, by import path, is still
+// resolved by resolver.
+func Resolves(resolver PackageNameResolver, names map[string]string) bool {
+	for importPath, name := range names {
+		if resolver.PackageName(importPath) != name {
+			return false
+		}
+	}
+	return true
+}
+
+type assumedNameResolver struct{}
 
+func (assumedNameResolver) PackageName(importPath string) string {
+	return pkgname.AssumedName(importPath)
+}
+
+type DependencyVisitor struct {
+	// emit sends a node to the channel, or appends it to the batch, of the
+	// visitor
+	emit     func(node ast.Node)
+	close    func()
+	resolver PackageNameResolver
+	fset     *token.FileSet
+
+	dirName     string
+	filePaths   map[*ast.File]string
 	fileImports map[string]struct{}
+	// hasDotImports is set when the current file has a dot-import, only then
+	// are unqualified identifiers emitted
+	hasDotImports bool
+	// declIdents are identifiers which name something rather than
+	// reference it, e.g. field names, labels, and selected names
+	declIdents map[*ast.Ident]struct{}
+	// scope is the innermost local scope, nil outside of functions
+	scope *scope
+}
+
+// scope holds the names declared in a local block, names declared in an
+// enclosing block are found through outer.
+type scope struct {
+	outer *scope
+	names map[string]struct{}
 }
 
+func (s *scope) lookup(name string) bool {
+	for ; s != nil; s = s.outer {
+		if _, ok := s.names[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDependencyVisitor returns a visitor which assumes imported packages are
+// named after their import path, see pkgname.AssumedName.
 func NewDependencyVisitor() (*DependencyVisitor, <-chan ast.Node) {
+	return NewDependencyVisitorWithResolver(assumedNameResolver{})
+}
+
+func NewDependencyVisitorWithResolver(resolver PackageNameResolver) (*DependencyVisitor, <-chan ast.Node) {
 	out := make(chan ast.Node)
 	v := &DependencyVisitor{
-		out: out,
+		emit: func(node ast.Node) {
+			out <- node
+		},
+		close: func() {
+			close(out)
+		},
+		resolver: resolver,
 	}
 
 	return v, out
 }
 
+// Extract returns the nodes a DependencyVisitor emits for pkgs, the packages
+// of a single directory parsed with fset. The batch is self-contained, every
+// node follows the *Package and *File node it belongs to, and packages and
+// files are walked ordered by name so it is the same for every run.
+func Extract(resolver PackageNameResolver, fset *token.FileSet, pkgs map[string]*ast.Package) []ast.Node {
+	var nodes []ast.Node
+	for _, name := range sortedKeys(pkgs) {
+		pkg := pkgs[name]
+		if pkgNode := NewPackage(fset, pkg); pkgNode != nil {
+			nodes = append(nodes, pkgNode)
+		}
+		for _, filename := range sortedKeys(pkg.Files) {
+			absPath, err := filepath.Abs(filename)
+			if err != nil {
+				continue
+			}
+			nodes = append(nodes, ExtractFile(resolver, fset, absPath, pkg.Files[filename])...)
+		}
+	}
+	return nodes
+}
+
+// ExtractFile returns the nodes a DependencyVisitor emits for the file at
+// absPath, starting with its *File node. The nodes of a file do not depend on
+// the other files of its package.
+func ExtractFile(resolver PackageNameResolver, fset *token.FileSet, absPath string, file *ast.File) []ast.Node {
+	var nodes []ast.Node
+	v := &DependencyVisitor{
+		emit: func(node ast.Node) {
+			nodes = append(nodes, node)
+		},
+		close:     func() {},
+		resolver:  resolver,
+		fset:      fset,
+		dirName:   filepath.Dir(absPath),
+		filePaths: map[*ast.File]string{file: absPath},
+	}
+	ast.Walk(v, file)
+	return nodes
+}
+
+// NewPackage returns the *Package node of pkg, nil if the directory of its
+// files cannot be determined.
+func NewPackage(fset *token.FileSet, pkg *ast.Package) *Package {
+	dirName, _, isExternalTest := packageFiles(pkg)
+	if dirName == "" {
+		return nil
+	}
+	return &Package{
+		Package:        pkg,
+		DirName:        dirName,
+		IsExternalTest: isExternalTest,
+		FileSet:        fset,
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// SetFileSet sets the file set the packages walked next were parsed with,
+// emitted nodes are positioned with it.
+func (v *DependencyVisitor) SetFileSet(fset *token.FileSet) {
+	v.fset = fset
+}
+
 func (v *DependencyVisitor) Visit(node ast.Node) ast.Visitor {
 	switch node := node.(type) {
 	case *ast.Package:
-		v.emitPackageAndFiles(node)
+		v.emitPackage(node)
 
 	case *ast.File:
 		v.fileImports = make(map[string]struct{})
+		v.hasDotImports = false
+		v.declIdents = make(map[*ast.Ident]struct{})
+		v.scope = nil
+		v.emitFile(node)
+		v.skipIdent(node.Name)
+		v.emitLinknames(node)
 
 	case *ast.ImportSpec:
 		v.emitImportSpec(node)
+		return nil
 
 	case *ast.FuncDecl:
 		v.emitFuncDecl(node)
+		v.skipIdent(node.Name)
+		v.walkFunc(node.Recv, node.Type, node.Body)
+		return nil
+
+	case *ast.Ident:
+		v.emitIdent(node)
 
 	case *ast.GenDecl:
 		switch node.Tok {
@@ -80,10 +307,22 @@ func (v *DependencyVisitor) Visit(node ast.Node) ast.Visitor {
 		case token.TYPE:
 			fallthrough
 		case token.VAR:
-			v.out <- node
+			v.emit(node)
+		}
+		for _, spec := range node.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				v.skipIdent(spec.Name)
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					v.skipIdent(name)
+				}
+			}
 		}
 
 	case *ast.SelectorExpr:
+		v.skipIdent(node.Sel)
+
 		// only references to external packages
 		if node.X == nil {
 			return v
@@ -99,102 +338,480 @@ func (v *DependencyVisitor) Visit(node ast.Node) ast.Visitor {
 		if _, ok := v.fileImports[impName]; !ok {
 			return v
 		}
+		// the import name is shadowed by a local declaration
+		if v.scope.lookup(impName) {
+			return v
+		}
 
-		v.out <- &SelectorExpr{
+		v.emit(&SelectorExpr{
 			SelectorExpr: node,
 			ImportName:   impName,
-		}
+		})
+
+	default:
+		return v.visitLocal(node)
 	}
 	return v
 }
 
-func (v *DependencyVisitor) emitPackageAndFiles(node *ast.Package) {
-	var setImportPathAndEmitPackage bool
-	var dirName string
-	for filename, astFile := range node.Files {
+// visitLocal walks the nodes which declare local names or open a block, all
+// other nodes are walked by ast.Walk.
+// Names are declared where their scope begins, e.g. after the right-hand
+// side of a short variable declaration, so `log := log.New(...)` still
+// references the log package.
+func (v *DependencyVisitor) visitLocal(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.FuncLit:
+		v.walkFunc(nil, node.Type, node.Body)
+
+	case *ast.BlockStmt:
+		v.openScope()
+		v.walkStmts(node.List)
+		v.closeScope()
+
+	case *ast.IfStmt:
+		v.openScope()
+		v.walk(node.Init)
+		v.walk(node.Cond)
+		v.walk(node.Body)
+		v.walk(node.Else)
+		v.closeScope()
+
+	case *ast.ForStmt:
+		v.openScope()
+		v.walk(node.Init)
+		v.walk(node.Cond)
+		v.walk(node.Post)
+		v.walk(node.Body)
+		v.closeScope()
+
+	case *ast.RangeStmt:
+		v.walk(node.X)
+		v.openScope()
+		if node.Tok == token.DEFINE {
+			v.declareExprs(node.Key, node.Value)
+		} else {
+			v.walk(node.Key)
+			v.walk(node.Value)
+		}
+		v.walk(node.Body)
+		v.closeScope()
+
+	case *ast.SwitchStmt:
+		v.openScope()
+		v.walk(node.Init)
+		v.walk(node.Tag)
+		v.walk(node.Body)
+		v.closeScope()
+
+	case *ast.TypeSwitchStmt:
+		v.openScope()
+		v.walk(node.Init)
+		// the symbol in `switch x := y.(type)` is declared in each clause
+		symbol := ""
+		if assign, ok := node.Assign.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+			v.walkExprs(assign.Rhs)
+			if ident, ok := assign.Lhs[0].(*ast.Ident); ok {
+				symbol = ident.Name
+			}
+		} else {
+			v.walk(node.Assign)
+		}
+		for _, stmt := range node.Body.List {
+			clause, ok := stmt.(*ast.CaseClause)
+			if !ok {
+				continue
+			}
+			v.openScope()
+			v.walkExprs(clause.List)
+			v.declare(symbol)
+			v.walkStmts(clause.Body)
+			v.closeScope()
+		}
+		v.closeScope()
+
+	case *ast.CaseClause:
+		v.openScope()
+		v.walkExprs(node.List)
+		v.walkStmts(node.Body)
+		v.closeScope()
+
+	case *ast.Field:
+		for _, name := range node.Names {
+			v.skipIdent(name)
+		}
+		return v
+
+	case *ast.KeyValueExpr:
+		// a key in a composite literal is most likely a field name
+		if key, ok := node.Key.(*ast.Ident); ok {
+			v.skipIdent(key)
+		}
+		return v
+
+	case *ast.LabeledStmt:
+		v.skipIdent(node.Label)
+		return v
+
+	case *ast.BranchStmt:
+		v.skipIdent(node.Label)
+		return v
+
+	case *ast.CommClause:
+		v.openScope()
+		v.walk(node.Comm)
+		v.walkStmts(node.Body)
+		v.closeScope()
+
+	case *ast.AssignStmt:
+		if node.Tok != token.DEFINE {
+			return v
+		}
+		v.walkExprs(node.Rhs)
+		v.declareExprs(node.Lhs...)
+
+	case *ast.DeclStmt:
+		// local declarations are not package declarations and are not
+		// emitted
+		genDecl, ok := node.Decl.(*ast.GenDecl)
+		if !ok {
+			return v
+		}
+		for _, spec := range genDecl.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				v.walk(spec.Type)
+				v.walkExprs(spec.Values)
+				for _, name := range spec.Names {
+					v.declare(name.Name)
+				}
+			case *ast.TypeSpec:
+				v.declare(spec.Name.Name)
+				v.walk(spec)
+			}
+		}
+
+	case *ast.TypeSpec:
+		// type parameters are in scope in the type parameter list and the
+		// type
+		v.openScope()
+		v.declareFields(node.TypeParams)
+		if node.TypeParams != nil {
+			v.walk(node.TypeParams)
+		}
+		v.walk(node.Type)
+		v.closeScope()
+
+	default:
+		return v
+	}
+	return nil
+}
+
+// walkFunc walks a function declaration or literal. Type parameters are in
+// scope in the signature and the body, the receiver, parameters, and results
+// are in scope in the body only.
+func (v *DependencyVisitor) walkFunc(recv *ast.FieldList, typ *ast.FuncType, body *ast.BlockStmt) {
+	v.openScope()
+	if recv != nil && len(recv.List) > 0 {
+		v.declareExprs(receiverTypeParams(recv.List[0].Type)...)
+	}
+	v.declareFields(typ.TypeParams)
+	if recv != nil {
+		v.walk(recv)
+	}
+	v.walk(typ)
+	if body != nil {
+		v.declareFields(recv)
+		v.declareFields(typ.Params)
+		v.declareFields(typ.Results)
+		v.walkStmts(body.List)
+	}
+	v.closeScope()
+}
+
+func (v *DependencyVisitor) walk(node ast.Node) {
+	if node == nil {
+		return
+	}
+	ast.Walk(v, node)
+}
+
+func (v *DependencyVisitor) walkExprs(exprs []ast.Expr) {
+	for _, expr := range exprs {
+		v.walk(expr)
+	}
+}
+
+func (v *DependencyVisitor) walkStmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		v.walk(stmt)
+	}
+}
+
+func (v *DependencyVisitor) openScope() {
+	v.scope = &scope{
+		outer: v.scope,
+		names: make(map[string]struct{}),
+	}
+}
+
+func (v *DependencyVisitor) closeScope() {
+	v.scope = v.scope.outer
+}
+
+func (v *DependencyVisitor) declare(name string) {
+	if name == "" || name == "_" {
+		return
+	}
+	v.scope.names[name] = struct{}{}
+}
+
+func (v *DependencyVisitor) declareExprs(exprs ...ast.Expr) {
+	for _, expr := range exprs {
+		if ident, ok := expr.(*ast.Ident); ok {
+			v.declare(ident.Name)
+		}
+	}
+}
+
+func (v *DependencyVisitor) declareFields(fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		for _, name := range field.Names {
+			v.declare(name.Name)
+		}
+	}
+}
+
+func (v *DependencyVisitor) emitPackage(node *ast.Package) {
+	var isExternalTest bool
+	v.dirName, v.filePaths, isExternalTest = packageFiles(node)
+	if v.dirName == "" {
+		return
+	}
+	v.emit(&Package{
+		Package:        node,
+		DirName:        v.dirName,
+		IsExternalTest: isExternalTest,
+		FileSet:        v.fset,
+	})
+}
+
+// packageFiles returns the directory and the absolute paths of the files of
+// pkg, and whether it is an external test package.
+func packageFiles(pkg *ast.Package) (string, map[*ast.File]string, bool) {
+	dirName := ""
+	filePaths := make(map[*ast.File]string, len(pkg.Files))
+	isExternalTest := strings.HasSuffix(pkg.Name, "_test")
+	for filename, astFile := range pkg.Files {
+		if !strings.HasSuffix(filename, "_test.go") {
+			isExternalTest = false
+		}
 		absPath, err := filepath.Abs(filename)
 		if err != nil {
 			continue
 		}
-		if !setImportPathAndEmitPackage {
-			dirName, _ = filepath.Split(absPath)
-			dirName = strings.TrimRight(dirName, "/")
-			v.out <- &Package{
-				Package: node,
-				DirName: dirName,
-			}
-			setImportPathAndEmitPackage = true
+		filePaths[astFile] = absPath
+		if dirName != "" {
+			continue
 		}
+		dir, _ := filepath.Split(absPath)
+		dirName = strings.TrimRight(dir, "/")
+	}
+	return dirName, filePaths, isExternalTest
+}
 
-		v.out <- &File{
-			File:    astFile,
-			AbsPath: absPath,
-			DirName: dirName,
+// emitFile emits files as they are walked so every declaration, import, and
+// selector emitted afterward belongs to the most recently emitted file.
+func (v *DependencyVisitor) emitFile(node *ast.File) {
+	absPath, ok := v.filePaths[node]
+	if !ok {
+		return
+	}
+	v.emit(&File{
+		File:    node,
+		AbsPath: absPath,
+		DirName: v.dirName,
+	})
+}
+
+// emitLinknames emits the //go:linkname directives of a file which bind a
+// local declaration to a declaration of another package.
+func (v *DependencyVisitor) emitLinknames(node *ast.File) {
+	for _, group := range node.Comments {
+		for _, comment := range group.List {
+			linkname, ok := parseLinkname(comment)
+			if !ok {
+				continue
+			}
+			linkname.PackageName = v.resolver.PackageName(linkname.ImportPath)
+			v.emit(linkname)
 		}
 	}
 }
 
+// parseLinkname parses //go:linkname localname importpath.name, the one
+// argument form only exports localname and is not a dependency.
+func parseLinkname(comment *ast.Comment) (*Linkname, bool) {
+	args, ok := strings.CutPrefix(comment.Text, "//go:linkname ")
+	if !ok {
+		return nil, false
+	}
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		return nil, false
+	}
+	target := fields[1]
+	slash := strings.LastIndex(target, "/")
+	dot := strings.Index(target[slash+1:], ".")
+	if dot <= 0 {
+		return nil, false
+	}
+	importPath := target[:slash+1+dot]
+	name := target[slash+1+dot+1:]
+	// (*T).M is the method T.M
+	name = strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+	if name == "" {
+		return nil, false
+	}
+	return &Linkname{
+		Comment:    comment,
+		LocalName:  fields[0],
+		ImportPath: importPath,
+		Name:       name,
+	}, true
+}
+
+// emitImportSpec emits a copy of the import spec with an unquoted path and
+// the name the import is referenced by, the parsed file is left as is so it
+// can still be type checked.
 func (v *DependencyVisitor) emitImportSpec(node *ast.ImportSpec) {
-	node.Path.Value = strings.Trim(node.Path.Value, "\"")
-	pieces := strings.Split(node.Path.Value, "/")
-	name := pieces[len(pieces)-1]
+	spec := *node
+	path := *node.Path
+	path.Value = strings.Trim(path.Value, "\"")
+	spec.Path = &path
+	if path.Value == "C" {
+		// cgo, C.name selectors are not package references
+		v.emit(&ImportSpec{
+			ImportSpec: &spec,
+		})
+		return
+	}
+	name := v.resolver.PackageName(path.Value)
 
 	isAliased := node.Name != nil
 	alias := ""
 
 	if isAliased {
 		alias = node.Name.String()
-		node.Name.Name = name
-		v.fileImports[alias] = struct{}{}
+		spec.Name = &ast.Ident{
+			NamePos: node.Name.NamePos,
+			Name:    name,
+		}
+		switch alias {
+		case ".":
+			v.hasDotImports = true
+		case "_":
+		default:
+			v.fileImports[alias] = struct{}{}
+		}
 	}
 
 	if !isAliased {
-		node.Name = &ast.Ident{
+		spec.Name = &ast.Ident{
 			Name: name,
 		}
 		v.fileImports[name] = struct{}{}
 	}
 
-	v.out <- &ImportSpec{
-		ImportSpec: node,
+	v.emit(&ImportSpec{
+		ImportSpec: &spec,
 		IsAliased:  isAliased,
 		Alias:      alias,
+	})
+}
+
+// emitIdent emits identifiers which may reference a declaration of a
+// dot-imported package.
+func (v *DependencyVisitor) emitIdent(node *ast.Ident) {
+	if !v.hasDotImports || node.Name == "_" {
+		return
+	}
+	if _, ok := v.declIdents[node]; ok {
+		return
+	}
+	if _, ok := v.fileImports[node.Name]; ok {
+		return
 	}
+	if v.scope.lookup(node.Name) {
+		return
+	}
+	v.emit(&Ident{
+		Ident: node,
+	})
+}
+
+func (v *DependencyVisitor) skipIdent(node *ast.Ident) {
+	if node == nil || v.declIdents == nil {
+		return
+	}
+	v.declIdents[node] = struct{}{}
 }
 
 func (v *DependencyVisitor) emitFuncDecl(node *ast.FuncDecl) {
 	receiverName := ""
 	qualifiedName := node.Name.String()
 
-	if node.Recv != nil {
+	if node.Recv != nil && len(node.Recv.List) > 0 {
 		// TODO: don't emit receiver functions/methods? we don't need them
-		var typName string
-		switch expr := node.Recv.List[0].Type.(type) {
-		case *ast.Ident:
-			typName = expr.String()
-		case *ast.StarExpr:
-			if expr.X == nil {
-				// panic error, invalid receiver method
-			}
-			ident, ok := expr.X.(*ast.Ident)
-			if !ok {
-				// panic error, invalid receiver method
-			}
-			typName = ident.String()
-		default:
-			// panic error, invalid receiver method
-		}
-		receiverName = typName
-		qualifiedName = typName + "." + node.Name.String()
+		receiverName = ReceiverTypeName(node.Recv.List[0].Type)
+		qualifiedName = receiverName + "." + node.Name.String()
 	}
 
-	v.out <- &FuncDecl{
+	v.emit(&FuncDecl{
 		FuncDecl:      node,
 		ReceiverName:  receiverName,
 		QualifiedName: qualifiedName,
+	})
+}
+
+// ReceiverTypeName returns the name of the type of a method receiver, e.g. T
+// for the receivers T, *T, T[K], and *T[K, V].
+func ReceiverTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.String()
+	case *ast.StarExpr:
+		return ReceiverTypeName(expr.X)
+	case *ast.ParenExpr:
+		return ReceiverTypeName(expr.X)
+	case *ast.IndexExpr:
+		return ReceiverTypeName(expr.X)
+	case *ast.IndexListExpr:
+		return ReceiverTypeName(expr.X)
+	}
+	return ""
+}
+
+// receiverTypeParams returns the type parameters of a method receiver, e.g.
+// K and V for the receiver *T[K, V].
+func receiverTypeParams(expr ast.Expr) []ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeParams(expr.X)
+	case *ast.ParenExpr:
+		return receiverTypeParams(expr.X)
+	case *ast.IndexExpr:
+		return []ast.Expr{expr.Index}
+	case *ast.IndexListExpr:
+		return expr.Indices
 	}
+	return nil
 }
 
 func (v *DependencyVisitor) Close() {
-	close(v.out)
+	v.close()
 }
@@ -12,6 +12,7 @@
 	"testing"
 
 	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/pkgname"
 )
 
 func TestDependencyVisitor_Visit_EmitsPackages(t *testing.T) {
@@ -216,8 +217,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -482,8 +484,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -647,8 +650,9 @@ func(path string, n *Node) {
 				fset := token.NewFileSet()
 				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 				if err != nil {
+					t.Errorf("%s: %s", testCase, err)
 					cancel()
-					t.Fatalf("%s: %s", testCase, err)
+					return
 				}
 
 				for _, pkg := range pkgs {
@@ -825,8 +829,9 @@ func(path string, n *Node) {
 				fset := token.NewFileSet()
 				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 				if err != nil {
+					t.Errorf("%s: %s", testCase, err)
 					cancel()
-					t.Fatalf("%s: %s", testCase, err)
+					return
 				}
 
 				for _, pkg := range pkgs {
@@ -1057,8 +1062,9 @@ func(path string, n *Node) {
 				fset := token.NewFileSet()
 				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 				if err != nil {
+					t.Errorf("%s: %s", testCase, err)
 					cancel()
-					t.Fatalf("%s: %s", testCase, err)
+					return
 				}
 
 				for _, pkg := range pkgs {
@@ -1118,6 +1124,161 @@ func(path string, n *Node) {
 	}
 }
 
+func TestDependencyVisitor_Visit_EmitsFunctions_GenericReceivers(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			{
+				"main.go",
+				nil,
+				"main",
+				`
+package main
+
+func (l *List[T]) Push(v T) {}
+
+type List[T any] struct{}
+
+type Map[K comparable, V any] map[K]V
+
+func (m Map[K, V]) Get(k K) V { return m[k] }
+
+func (m *Map[K, _]) Delete(k K) {}
+
+func (l (List[T])) Len() int { return 0 }
+`,
+			},
+		},
+		"",
+		"",
+	}
+
+	makeTree(t, tree)
+
+	testCase := ""
+	dirOut := make(chan string)
+	depVis, nodeOut := internalAST.NewDependencyVisitor()
+
+	expectedNamesInOrder := []string{
+		"List.Push",
+		"Map.Get",
+		"Map.Delete",
+		"List.Len",
+	}
+	directoryPathsInOrder := []string{}
+	walkTree(
+		tree,
+		tree.name,
+		func(path string, n *Node) {
+			if n.entries == nil {
+				return
+			}
+
+			directoryPathsInOrder = append(directoryPathsInOrder, tmpDir+string(filepath.Separator)+path)
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for _, dirPath := range directoryPathsInOrder {
+			dirOut <- dirPath
+		}
+		close(dirOut)
+	}()
+
+	go func() {
+		for {
+			select {
+			case dirPath, ok := <-dirOut:
+				if !ok {
+					depVis.Close()
+					return
+				}
+				fset := token.NewFileSet()
+				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
+				if err != nil {
+					t.Errorf("%s: %s", testCase, err)
+					cancel()
+					return
+				}
+
+				for _, pkg := range pkgs {
+					ast.Walk(depVis, pkg)
+				}
+
+			case <-ctx.Done():
+				depVis.Close()
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case node, ok := <-nodeOut:
+				if !ok {
+					cancel()
+					return
+				}
+				switch node := node.(type) {
+				case *internalAST.FuncDecl:
+					if len(expectedNamesInOrder) == 0 {
+						cancel()
+						t.Errorf(
+							"%s: read more functions than expected: %s",
+							testCase,
+							node.QualifiedName,
+						)
+						return
+					}
+					if expectedNamesInOrder[0] != node.QualifiedName {
+						cancel()
+						t.Errorf(
+							"%s: expected function named %s, got %s",
+							testCase,
+							expectedNamesInOrder[0],
+							node.QualifiedName,
+						)
+					}
+					expectedNamesInOrder = expectedNamesInOrder[1:]
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	<-ctx.Done()
+
+	if len(expectedNamesInOrder) != 0 {
+		t.Errorf(
+			"%s: expected function names never received: %s",
+			testCase,
+			strings.Join(expectedNamesInOrder, ", "),
+		)
+	}
+}
+
 func TestDependencyVisitor_Visit_EmitsSelectorExprs(t *testing.T) {
 	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
 	// -- START -- //
@@ -1229,8 +1390,9 @@ func(path string, n *Node) {
 				fset := token.NewFileSet()
 				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 				if err != nil {
+					t.Errorf("%s: %s", testCase, err)
 					cancel()
-					t.Fatalf("%s: %s", testCase, err)
+					return
 				}
 
 				for _, pkg := range pkgs {
@@ -1297,6 +1459,474 @@ func(path string, n *Node) {
 	}
 }
 
+func TestDependencyVisitor_Visit_EmitsSelectorExprs_ResolvedPackageNames(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			{
+				"main.go",
+				nil,
+				"main",
+				`
+package main
+
+import (
+	"example.com/x/go-foo"
+	"example.com/x/renamed"
+	"example.com/x/v2"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	yaml.Marshal(nil)
+	foo.Bar()
+	other.Baz()
+	x.Qux()
+	renamed.Missing()
+}
+`,
+			},
+		},
+		"",
+		"",
+	}
+
+	makeTree(t, tree)
+
+	testCase := ""
+	dirOut := make(chan string)
+	depVis, nodeOut := internalAST.NewDependencyVisitorWithResolver(
+		resolverFunc(func(importPath string) string {
+			if importPath == "example.com/x/renamed" {
+				return "other"
+			}
+			return pkgname.AssumedName(importPath)
+		}),
+	)
+
+	expectedNamesInOrder := []string{
+		"yaml.Marshal",
+		"foo.Bar",
+		"other.Baz",
+		"x.Qux",
+	}
+	directoryPathsInOrder := []string{}
+	walkTree(
+		tree,
+		tree.name,
+		func(path string, n *Node) {
+			if n.entries == nil {
+				return
+			}
+
+			directoryPathsInOrder = append(directoryPathsInOrder, tmpDir+string(filepath.Separator)+path)
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for _, dirPath := range directoryPathsInOrder {
+			dirOut <- dirPath
+		}
+		close(dirOut)
+	}()
+
+	go func() {
+		for {
+			select {
+			case dirPath, ok := <-dirOut:
+				if !ok {
+					depVis.Close()
+					return
+				}
+				fset := token.NewFileSet()
+				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
+				if err != nil {
+					cancel()
+					t.Errorf("%s: %s", testCase, err)
+					return
+				}
+
+				for _, pkg := range pkgs {
+					ast.Walk(depVis, pkg)
+				}
+
+			case <-ctx.Done():
+				depVis.Close()
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case node, ok := <-nodeOut:
+				if !ok {
+					cancel()
+					return
+				}
+				switch node := node.(type) {
+				case *internalAST.SelectorExpr:
+					x, ok := node.X.(*ast.Ident)
+					if !ok {
+						cancel()
+						t.Errorf("%s: expected selector node to have X", testCase)
+					}
+					actual := x.String() + "." + node.Sel.String()
+
+					if len(expectedNamesInOrder) == 0 {
+						cancel()
+						t.Errorf(
+							"%s: read more selectors than expected: %s",
+							testCase,
+							actual,
+						)
+						return
+					}
+					if expectedNamesInOrder[0] != actual {
+						cancel()
+						t.Errorf(
+							"%s: expected selector %s, got %s",
+							testCase,
+							expectedNamesInOrder[0],
+							actual,
+						)
+					}
+					expectedNamesInOrder = expectedNamesInOrder[1:]
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	<-ctx.Done()
+
+	if len(expectedNamesInOrder) != 0 {
+		t.Errorf(
+			"%s: expected selectors never received: %s",
+			testCase,
+			strings.Join(expectedNamesInOrder, ", "),
+		)
+	}
+}
+
+func TestDependencyVisitor_Visit_EmitsSelectorExprs_ShadowedImports(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			{
+				"main.go",
+				nil,
+				"main",
+				`
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+type config struct{ Name string }
+
+func (log *config) Method() {
+	log.Print()
+}
+
+func params(log *log.Logger, errors []error) {
+	log.Print()
+	errors.Is()
+}
+
+func ranges(values []string) {
+	for _, strings := range values {
+		strings.TrimSpace()
+	}
+	strings.TrimSpace("")
+}
+
+func closures() {
+	f := func(log *config) {
+		log.Print()
+	}
+	g := func() {
+		log.Print()
+	}
+	_, _ = f, g
+}
+
+func typeSwitches(v any) {
+	switch errors := v.(type) {
+	case errors.Error:
+		errors.Error()
+	}
+	errors.New("")
+}
+
+func shortVarDecls() {
+	log := log.New(nil, "", 0)
+	log.Print()
+	{
+		var errors = errors.New("")
+		errors.Error()
+	}
+	errors.Join()
+}
+`,
+			},
+		},
+		"",
+		"",
+	}
+
+	makeTree(t, tree)
+
+	testCase := ""
+	dirOut := make(chan string)
+	depVis, nodeOut := internalAST.NewDependencyVisitor()
+
+	expectedNamesInOrder := []string{
+		"log.Logger",
+		"strings.TrimSpace",
+		"log.Print",
+		"errors.Error",
+		"errors.New",
+		"log.New",
+		"errors.New",
+		"errors.Join",
+	}
+	directoryPathsInOrder := []string{}
+	walkTree(
+		tree,
+		tree.name,
+		func(path string, n *Node) {
+			if n.entries == nil {
+				return
+			}
+
+			directoryPathsInOrder = append(directoryPathsInOrder, tmpDir+string(filepath.Separator)+path)
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for _, dirPath := range directoryPathsInOrder {
+			dirOut <- dirPath
+		}
+		close(dirOut)
+	}()
+
+	go func() {
+		for {
+			select {
+			case dirPath, ok := <-dirOut:
+				if !ok {
+					depVis.Close()
+					return
+				}
+				fset := token.NewFileSet()
+				pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
+				if err != nil {
+					cancel()
+					t.Errorf("%s: %s", testCase, err)
+					return
+				}
+
+				for _, pkg := range pkgs {
+					ast.Walk(depVis, pkg)
+				}
+
+			case <-ctx.Done():
+				depVis.Close()
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case node, ok := <-nodeOut:
+				if !ok {
+					cancel()
+					return
+				}
+				switch node := node.(type) {
+				case *internalAST.SelectorExpr:
+					x, ok := node.X.(*ast.Ident)
+					if !ok {
+						cancel()
+						t.Errorf("%s: expected selector node to have X", testCase)
+					}
+					actual := x.String() + "." + node.Sel.String()
+
+					if len(expectedNamesInOrder) == 0 {
+						cancel()
+						t.Errorf(
+							"%s: read more selectors than expected: %s",
+							testCase,
+							actual,
+						)
+						return
+					}
+					if expectedNamesInOrder[0] != actual {
+						cancel()
+						t.Errorf(
+							"%s: expected selector %s, got %s",
+							testCase,
+							expectedNamesInOrder[0],
+							actual,
+						)
+					}
+					expectedNamesInOrder = expectedNamesInOrder[1:]
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	<-ctx.Done()
+
+	if len(expectedNamesInOrder) != 0 {
+		t.Errorf(
+			"%s: expected selectors never received: %s",
+			testCase,
+			strings.Join(expectedNamesInOrder, ", "),
+		)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	sources := map[string]string{
+		"/tmp/m/a/b.go": `package a
+
+import x "example.com/m/b"
+
+var _ = x.B
+`,
+		"/tmp/m/a/a.go": `package a
+
+import "example.com/m/c"
+
+func A() { c.C() }
+`,
+	}
+
+	parse := func() (*token.FileSet, map[string]*ast.Package) {
+		fset := token.NewFileSet()
+		pkg := &ast.Package{Name: "a", Files: make(map[string]*ast.File)}
+		for filename, src := range sources {
+			file, err := parser.ParseFile(fset, filename, src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg.Files[filename] = file
+		}
+		return fset, map[string]*ast.Package{"a": pkg}
+	}
+
+	describe := func(nodes []ast.Node) []string {
+		var described []string
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case *internalAST.Package:
+				described = append(described, "package "+node.Name)
+			case *internalAST.File:
+				described = append(described, "file "+node.AbsPath)
+			case *internalAST.ImportSpec:
+				described = append(described, "import "+node.Name.Name+" "+node.Alias+" "+node.Path.Value)
+			case *internalAST.FuncDecl:
+				described = append(described, "func "+node.QualifiedName)
+			case *internalAST.SelectorExpr:
+				described = append(described, "selector "+node.ImportName+"."+node.Sel.Name)
+			}
+		}
+		return described
+	}
+
+	expected := []string{
+		"package a",
+		"file /tmp/m/a/a.go",
+		"import c  example.com/m/c",
+		"func A",
+		"selector c.C",
+		"file /tmp/m/a/b.go",
+		"import b x example.com/m/b",
+		"selector x.B",
+	}
+
+	fset, pkgs := parse()
+	actual := describe(internalAST.Extract(resolverFunc(pkgname.AssumedName), fset, pkgs))
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected nodes\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	// the parsed files are left untouched, they are type checked after
+	// extraction
+	for _, file := range pkgs["a"].Files {
+		for _, spec := range file.Imports {
+			if !strings.HasPrefix(spec.Path.Value, `"`) {
+				t.Errorf("expected import path %s to remain quoted", spec.Path.Value)
+			}
+			if spec.Name != nil && spec.Name.Name != "x" {
+				t.Errorf("expected import name %s to remain x", spec.Name.Name)
+			}
+		}
+	}
+	fset, pkgs = parse()
+	again := describe(internalAST.Extract(resolverFunc(pkgname.AssumedName), fset, pkgs))
+	if strings.Join(again, "\n") != strings.Join(actual, "\n") {
+		t.Errorf("expected the same nodes for every extraction, got\n%s\nthen\n%s", strings.Join(actual, "\n"), strings.Join(again, "\n"))
+	}
+}
+
+type resolverFunc func(importPath string) string
+
+func (fn resolverFunc) PackageName(importPath string) string {
+	return fn(importPath)
+}
+
 // REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L449
 type Node struct {
 	name    string
@@ -0,0 +1,25 @@
+package ast
+
+import (
+	"fmt"
+	"go/token"
+)
+
+// NodeError is an error adding a node to the PrimitiveBuilder. Pos is the
+// position of the node, only its Filename is known for packages and for
+// nodes parsed without a file set.
+type NodeError struct {
+	Pos token.Position
+	Err error
+}
+
+func (err *NodeError) Error() string {
+	if err.Pos.Filename == "" && !err.Pos.IsValid() {
+		return err.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", err.Pos, err.Err)
+}
+
+func (err *NodeError) Unwrap() error {
+	return err.Err
+}
@@ -1,24 +1,50 @@
 package ast
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
 )
 
 type PrimitiveBuilder struct {
 	modulePath    string
 	moduleRootDir string
+	edges         config.Edges
 
 	packagesByUID map[string]*internal.Package
 	filesByUID    map[string]*internal.File
 
 	curPkg  *internal.Package
 	curFile *internal.File
+	// curFileSet positions the nodes of the current package
+	curFileSet *token.FileSet
+
+	// methods whose receiver type is not declared yet, by package and
+	// receiver type name
+	pendingMethods map[*internal.Package]map[string][]*internal.Decl
+	// unqualified identifiers in files with dot-imports, resolved once every
+	// package is added
+	pendingIdents []pendingIdent
+
+	// names are the interned import names and paths, repeated by most
+	// files of a module
+	names map[string]string
+}
+
+type pendingIdent struct {
+	file *internal.File
+	name string
+	pos  token.Position
 }
 
 func NewPrimitiveBuilder(modulePath, moduleRootDir string) *PrimitiveBuilder {
@@ -28,72 +54,125 @@ func NewPrimitiveBuilder(modulePath, moduleRootDir string) *PrimitiveBuilder {
 
 		packagesByUID: make(map[string]*internal.Package),
 		filesByUID:    make(map[string]*internal.File),
+
+		pendingMethods: make(map[*internal.Package]map[string][]*internal.Decl),
+
+		names: make(map[string]string),
 	}
 }
 
+// SetEdges selects which imports are edges of the package graph, imports
+// with a referenced declaration by default.
+func (builder *PrimitiveBuilder) SetEdges(edges config.Edges) {
+	builder.edges = edges
+}
+
 func (builder *PrimitiveBuilder) MarkupImportCycles() error {
-	stk := NewFileStack()
-	for _, baseFile := range builder.filesByUID {
-		stk.Push(baseFile)
-		err := builder.markupImportCycles(baseFile, stk)
-		if err != nil {
-			return err
-		}
-		stk.Pop()
+	err := builder.resolveDotImports()
+	if err != nil {
+		return err
 	}
+	MarkupImportCycles(builder.Packages(), builder.edges)
 	return nil
 }
 
-func (builder *PrimitiveBuilder) markupImportCycles(
-	baseFile *internal.File,
-	stk *FileStack,
-) error {
-	for _, imp := range baseFile.Imports {
-		for _, typ := range imp.ReferencedTypes {
-			refFile := typ.File
-			if stk.Contains(refFile) {
-				for i := stk.Len() - 1; i > 0; i-- {
-					curFile := stk.At(i)
-					curFile.InImportCycle = true
-					curFile.Package.InImportCycle = true
-					imp.InImportCycle = true
-					imp.ReferencedFilesInCycle[refFile.UID()] = refFile
-					if curFile.UID() == baseFile.UID() {
-						return nil
-					}
-				}
+// MarkupImportCycles marks up the import cycles between the files of pkgs,
+// and between pkgs through the given edges, replacing any previous markup,
+// e.g. once pkgs are filtered.
+func MarkupImportCycles(pkgs []*internal.Package, edges config.Edges) {
+	sorted := slices.Clone(pkgs)
+	slices.SortFunc(sorted, func(a, b *internal.Package) int {
+		return cmp.Compare(a.UID(), b.UID())
+	})
+	markupFileImportCycles(sorted)
+	markupPackageImportCycles(sorted, edges)
+}
+
+func markupFileImportCycles(pkgs []*internal.Package) {
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			file.InImportCycle = false
+			file.Component = nil
+			for _, imp := range file.Imports {
+				imp.ReferencedFilesInCycle = nil
 			}
-			stk.Push(refFile)
-			err := builder.markupImportCycles(refFile, stk)
-			if err != nil {
-				return err
+		}
+	}
+
+	g := graph.Files(pkgs)
+	for id, members := range g.Components() {
+		component := &internal.FileComponent{
+			ID:      id,
+			Files:   make([]*internal.File, 0, len(members)),
+			IsCycle: g.IsCycle(members),
+		}
+		for _, node := range members {
+			component.Files = append(component.Files, node.File)
+			node.File.Component = component
+			node.File.InImportCycle = component.IsCycle
+		}
+	}
+
+	for _, e := range g.Edges() {
+		if !e.From.File.InImportCycle || e.From.File.Component != e.To.File.Component {
+			continue
+		}
+		for _, prov := range e.Provenance {
+			// allocated for the imports in a cycle only
+			if prov.Import.ReferencedFilesInCycle == nil {
+				prov.Import.ReferencedFilesInCycle = make(map[string]*internal.File)
 			}
-			stk.Pop()
+			prov.Import.ReferencedFilesInCycle[e.To.File.UID()] = e.To.File
 		}
 	}
-	for _, refFile := range baseFile.ReferencedFiles() {
-		// If you eventually import yourself, it's a cycle
-		if stk.Contains(refFile) {
-			for i := stk.Len() - 1; i > 0; i-- {
-				curFile := stk.At(i)
-				curFile.InImportCycle = true
-				curFile.Package.InImportCycle = true
-				if curFile.UID() == baseFile.UID() {
-					return nil
-				}
+}
+
+func markupPackageImportCycles(pkgs []*internal.Package, edges config.Edges) {
+	for _, pkg := range pkgs {
+		pkg.InImportCycle = false
+		pkg.Component = nil
+		for _, file := range pkg.Files {
+			for _, imp := range file.Imports {
+				imp.InImportCycle = false
 			}
 		}
-		stk.Push(refFile)
-		err := builder.markupImportCycles(refFile, stk)
-		if err != nil {
-			return err
+	}
+
+	g := graph.Packages(pkgs, edges)
+	for id, members := range g.Components() {
+		component := &internal.PackageComponent{
+			ID:       id,
+			Packages: make([]*internal.Package, 0, len(members)),
+			IsCycle:  g.IsCycle(members),
+		}
+		for _, node := range members {
+			component.Packages = append(component.Packages, node.Package)
+			node.Package.Component = component
+			node.Package.InImportCycle = component.IsCycle
+		}
+	}
+
+	for _, e := range g.Edges() {
+		if !e.From.Package.InImportCycle || e.From.Package.Component != e.To.Package.Component {
+			continue
+		}
+		for _, prov := range e.Provenance {
+			prov.Import.InImportCycle = true
 		}
-		stk.Pop()
 	}
-	return nil
 }
 
+// AddNode adds a node emitted by the DependencyVisitor, an error is a
+// *NodeError positioned at the node.
 func (builder *PrimitiveBuilder) AddNode(node ast.Node) error {
+	err := builder.addNode(node)
+	if err == nil {
+		return nil
+	}
+	return &NodeError{Pos: builder.nodePosition(node), Err: err}
+}
+
+func (builder *PrimitiveBuilder) addNode(node ast.Node) error {
 	switch node := node.(type) {
 	case *Package:
 		return builder.addPackage(node)
@@ -112,11 +191,50 @@ func (builder *PrimitiveBuilder) AddNode(node ast.Node) error {
 
 	case *SelectorExpr:
 		return builder.addSelectorExpr(node)
+
+	case *Ident:
+		return builder.addIdent(node)
+
+	case *Linkname:
+		return builder.addLinkname(node)
+
+	case *Reference:
+		return builder.addReference(node)
 	}
 
 	return nil
 }
 
+// nodePosition returns the position of node, the file it is in when the
+// position is not known.
+func (builder *PrimitiveBuilder) nodePosition(node ast.Node) token.Position {
+	var pos token.Position
+	switch node := node.(type) {
+	case *Package:
+		return token.Position{Filename: node.DirName}
+	case *File:
+		pos = builder.position(node.Pos())
+		if pos.Filename == "" {
+			pos.Filename = node.AbsPath
+		}
+		return pos
+	case *ImportSpec:
+		pos = builder.position(node.Path.Pos())
+	case *FuncDecl:
+		pos = builder.position(node.Name.Pos())
+	case *SelectorExpr:
+		pos = builder.position(node.Sel.Pos())
+	case *Reference:
+		pos = node.Position
+	default:
+		pos = builder.position(node.Pos())
+	}
+	if pos.Filename == "" && builder.curFile != nil {
+		pos.Filename = builder.curFile.AbsPath
+	}
+	return pos
+}
+
 func (builder *PrimitiveBuilder) Files() []*internal.File {
 	files := make([]*internal.File, 0, len(builder.filesByUID))
 	for _, file := range builder.filesByUID {
@@ -141,6 +259,7 @@ func (builder *PrimitiveBuilder) addPackage(node *Package) error {
 		node.Name,
 		len(node.Files),
 	)
+	newPkg.IsExternalTest = node.IsExternalTest
 	newPkgUID := newPkg.UID()
 
 	pkg, pkgExists := builder.packagesByUID[newPkgUID]
@@ -172,9 +291,19 @@ func (builder *PrimitiveBuilder) addPackage(node *Package) error {
 	}
 
 	builder.curPkg = builder.packagesByUID[newPkgUID]
+	builder.curFileSet = node.FileSet
 	return nil
 }
 
+// position returns the position of pos in the current package, unknown
+// without a file set.
+func (builder *PrimitiveBuilder) position(pos token.Pos) token.Position {
+	if builder.curFileSet == nil || !pos.IsValid() {
+		return token.Position{}
+	}
+	return builder.curFileSet.Position(pos)
+}
+
 func (builder *PrimitiveBuilder) addFile(node *File) error {
 	if builder.curPkg == nil {
 		return fmt.Errorf("add file: no package defined: %s", node.AbsPath)
@@ -183,9 +312,16 @@ func (builder *PrimitiveBuilder) addFile(node *File) error {
 		Package:  builder.packagesByUID[builder.curPkg.UID()],
 		FileName: filepath.Base(node.AbsPath),
 		AbsPath:  node.AbsPath,
+		Kind:     internal.ProductionFile,
 		Imports:  make(map[string]*internal.Import),
 		Decls:    make(map[string]*internal.Decl),
 	}
+	if strings.HasSuffix(file.FileName, "_test.go") {
+		file.Kind = internal.InPackageTestFile
+		if builder.curPkg.IsExternalTest {
+			file.Kind = internal.ExternalTestFile
+		}
+	}
 	fileUID := file.UID()
 	if _, ok := builder.filesByUID[fileUID]; ok {
 		return fmt.Errorf("add file: duplicate file: %s", node.AbsPath)
@@ -206,36 +342,80 @@ func (builder *PrimitiveBuilder) addImport(node *ImportSpec) error {
 	if builder.curFile == nil {
 		return fmt.Errorf("add import: no file defined: %s \"%s\"", node.Name.String(), node.Path.Value)
 	}
+	if node.Path.Value == "C" {
+		builder.curFile.IsCgo = true
+		return nil
+	}
 	imp := &internal.Import{
-		Name:                   node.Name.String(),
-		Path:                   node.Path.Value,
-		ReferencedTypes:        make(map[string]*internal.Decl),
-		ReferencedFilesInCycle: make(map[string]*internal.File),
+		Name:            builder.intern(node.Name.String()),
+		Path:            builder.intern(node.Path.Value),
+		Pos:             builder.position(node.Path.Pos()),
+		ReferencedTypes: make(map[string]*internal.Decl),
 	}
 	if node.IsAliased {
-		imp.Name = node.Alias
+		imp.Name = builder.intern(node.Alias)
+		imp.Pos = builder.position(node.Name.Pos())
 	}
 	impUID := imp.UID()
 	if _, ok := builder.curFile.Imports[impUID]; ok {
 		return fmt.Errorf("add import: duplicate import: %s \"%s\"", node.Name.String(), node.Path.Value)
 	}
+	imp.Package = builder.importedPackage(imp.Path, node.Name.String())
 
-	// if the package exists, use it, otherwise use a stub
-	pkg := buildPackage(builder.modulePath, builder.moduleRootDir, imp.Path, imp.Name, 1)
-	pkg.IsStub = true
-	if _, ok := builder.packagesByUID[pkg.UID()]; ok {
-		pkg = builder.packagesByUID[pkg.UID()]
-	} else {
-		fileStub := buildStubFile(pkg)
-		pkg.Files[fileStub.UID()] = fileStub
-		builder.filesByUID[fileStub.UID()] = fileStub
-		builder.packagesByUID[pkg.UID()] = pkg
+	builder.curFile.Imports[impUID] = imp
+	return nil
+}
+
+// intern returns the first of the strings equal to s added, so strings
+// repeated across the files of a module are stored once.
+func (builder *PrimitiveBuilder) intern(s string) string {
+	if interned, ok := builder.names[s]; ok {
+		return interned
 	}
+	builder.names[s] = s
+	return s
+}
 
-	imp.Package = pkg
+// importedPackage returns the package at path, a stub package named name if
+// it has not been added yet.
+func (builder *PrimitiveBuilder) importedPackage(path, name string) *internal.Package {
+	pkg := buildPackage(builder.modulePath, builder.moduleRootDir, path, name, 1)
+	pkg.IsStub = true
+	if existing, ok := builder.packagesByUID[pkg.UID()]; ok {
+		return existing
+	}
+	fileStub := buildStubFile(pkg)
+	pkg.Files[fileStub.UID()] = fileStub
+	builder.filesByUID[fileStub.UID()] = fileStub
+	builder.packagesByUID[pkg.UID()] = pkg
+	return pkg
+}
 
-	builder.curFile.Imports[impUID] = imp
-	return nil
+func (builder *PrimitiveBuilder) addLinkname(node *Linkname) error {
+	if builder.curPkg == nil {
+		return fmt.Errorf("add linkname: no package defined: %s", node.Text)
+	}
+	if builder.curFile == nil {
+		return fmt.Errorf("add linkname: no file defined: %s", node.Text)
+	}
+	// a declaration of the package itself is not a dependency
+	if node.ImportPath == builder.curPkg.ImportPath() {
+		return nil
+	}
+	imp := &internal.Import{
+		Name:            builder.intern(node.PackageName),
+		Path:            builder.intern(node.ImportPath),
+		IsLinkname:      true,
+		Pos:             builder.position(node.Comment.Pos()),
+		ReferencedTypes: make(map[string]*internal.Decl),
+	}
+	if existing, ok := builder.curFile.Imports[imp.UID()]; ok {
+		imp = existing
+	} else {
+		imp.Package = builder.importedPackage(imp.Path, imp.Name)
+		builder.curFile.Imports[imp.UID()] = imp
+	}
+	return referenceDeclByName(imp, node.Name, builder.position(node.Comment.Pos()))
 }
 
 func (builder *PrimitiveBuilder) addFuncDecl(node *FuncDecl) error {
@@ -250,6 +430,9 @@ func (builder *PrimitiveBuilder) addFuncDecl(node *FuncDecl) error {
 	}
 	declUID := node.QualifiedName
 	if _, ok := builder.curFile.Decls[declUID]; ok {
+		if isRedeclarable(node.Name.String(), node.ReceiverName) {
+			return nil
+		}
 		return fmt.Errorf("add func decl: duplicate declaration: %s", node.QualifiedName)
 	}
 	// TODO: receiver methods should never be received and should be skipped
@@ -264,13 +447,41 @@ func (builder *PrimitiveBuilder) addFuncDecl(node *FuncDecl) error {
 		File:         builder.curFile,
 		ReceiverDecl: receiverDecl,
 		Name:         node.Name.String(),
+		Pos:          builder.position(node.Name.Pos()),
 	}
 	decl = builder.fixupStubDecl(decl)
 	builder.curFile.Decls[declUID] = decl
+	if node.ReceiverName != "" && receiverDecl == nil {
+		builder.addPendingMethod(node.ReceiverName, decl)
+	}
 
 	return nil
 }
 
+// addPendingMethod defers attaching a method to its receiver type until the
+// type is declared by a file added later.
+func (builder *PrimitiveBuilder) addPendingMethod(receiverName string, decl *internal.Decl) {
+	pending, ok := builder.pendingMethods[builder.curPkg]
+	if !ok {
+		pending = make(map[string][]*internal.Decl)
+		builder.pendingMethods[builder.curPkg] = pending
+	}
+	pending[receiverName] = append(pending[receiverName], decl)
+}
+
+// attachPendingMethods attaches the methods added before their receiver type
+// was declared.
+func (builder *PrimitiveBuilder) attachPendingMethods(receiverDecl *internal.Decl) {
+	pending, ok := builder.pendingMethods[builder.curPkg]
+	if !ok {
+		return
+	}
+	for _, decl := range pending[receiverDecl.Name] {
+		decl.ReceiverDecl = receiverDecl
+	}
+	delete(pending, receiverDecl.Name)
+}
+
 func (builder *PrimitiveBuilder) addGenDecl(node *ast.GenDecl) error {
 	if builder.curPkg == nil {
 		return errors.New("add gen decl: no package defined")
@@ -285,7 +496,10 @@ func (builder *PrimitiveBuilder) addGenDecl(node *ast.GenDecl) error {
 				return errors.New("add gen decl: invalid declaration")
 			}
 			if _, ok := builder.curFile.Decls[spec.Name.String()]; ok {
-				return errors.New("add gen decl: duplicate declaration")
+				if isRedeclarable(spec.Name.String(), "") {
+					continue
+				}
+				return fmt.Errorf("add gen decl: duplicate declaration: %s", spec.Name.String())
 			}
 			if spec.Name.String() == "" {
 				return errors.New("add gen decl: invalid name")
@@ -294,9 +508,11 @@ func (builder *PrimitiveBuilder) addGenDecl(node *ast.GenDecl) error {
 				File:         builder.curFile,
 				ReceiverDecl: nil,
 				Name:         spec.Name.String(),
+				Pos:          builder.position(spec.Name.Pos()),
 			}
 			decl = builder.fixupStubDecl(decl)
 			builder.curFile.Decls[decl.UID()] = decl
+			builder.attachPendingMethods(decl)
 
 		case *ast.ValueSpec:
 			if node.Tok != token.CONST && node.Tok != token.VAR {
@@ -304,7 +520,10 @@ func (builder *PrimitiveBuilder) addGenDecl(node *ast.GenDecl) error {
 			}
 			for _, name := range spec.Names {
 				if _, ok := builder.curFile.Decls[name.String()]; ok {
-					return errors.New("add gen decl: duplicate declaration")
+					if isRedeclarable(name.String(), "") {
+						continue
+					}
+					return fmt.Errorf("add gen decl: duplicate declaration: %s", name.String())
 				}
 				if name.String() == "" {
 					return errors.New("add gen decl: invalid constant or variable name")
@@ -313,6 +532,7 @@ func (builder *PrimitiveBuilder) addGenDecl(node *ast.GenDecl) error {
 					File:         builder.curFile,
 					ReceiverDecl: nil,
 					Name:         name.String(),
+					Pos:          builder.position(name.Pos()),
 				}
 				decl = builder.fixupStubDecl(decl)
 				builder.curFile.Decls[decl.UID()] = decl
@@ -338,17 +558,139 @@ func (builder *PrimitiveBuilder) addSelectorExpr(node *SelectorExpr) error {
 		return fmt.Errorf("add selector expr: no import defined: %s", node.Sel.String())
 	}
 
+	return referenceDeclByName(imp, node.Sel.String(), builder.position(node.Sel.Pos()))
+}
+
+func (builder *PrimitiveBuilder) addIdent(node *Ident) error {
+	if builder.curPkg == nil {
+		return fmt.Errorf("add ident: no package defined: %s", node.Name)
+	}
+	if builder.curFile == nil {
+		return fmt.Errorf("add ident: no file defined: %s", node.Name)
+	}
+	builder.pendingIdents = append(builder.pendingIdents, pendingIdent{
+		file: builder.curFile,
+		name: node.Name,
+		pos:  builder.position(node.Pos()),
+	})
+	return nil
+}
+
+// resolveDotImports resolves the unqualified identifiers of files with
+// dot-imports against the declarations of the dot-imported packages.
+// Identifiers declared by the file's own package or by none of the
+// dot-imported packages are skipped. A package outside the module is only
+// known by the identifiers referenced from it, so an identifier which is not
+// predeclared is attributed to it when it is the file's only dot-imported
+// package outside the module.
+func (builder *PrimitiveBuilder) resolveDotImports() error {
+	for _, ident := range builder.pendingIdents {
+		if declaredBy(ident.file.Package, ident.name) != nil {
+			continue
+		}
+
+		var found bool
+		var stubImps []*internal.Import
+		for _, imp := range sortedImports(ident.file) {
+			if !imp.IsDotImport() || imp.Package == nil {
+				continue
+			}
+			if imp.Package.IsStub {
+				stubImps = append(stubImps, imp)
+				continue
+			}
+			decl := declaredBy(imp.Package, ident.name)
+			if decl == nil {
+				continue
+			}
+			imp.ReferencedTypes[decl.UID()] = decl
+			imp.Uses = append(imp.Uses, &internal.Use{Decl: decl, Pos: ident.pos})
+			found = true
+			break
+		}
+		if found || len(stubImps) != 1 || types.Universe.Lookup(ident.name) != nil {
+			continue
+		}
+		err := referenceDeclByName(stubImps[0], ident.name, ident.pos)
+		if err != nil {
+			return err
+		}
+	}
+	builder.pendingIdents = nil
+	return nil
+}
+
+// declaredBy returns the package level declaration named name.
+func declaredBy(pkg *internal.Package, name string) *internal.Decl {
+	for _, file := range pkg.Files {
+		if file.IsStub {
+			continue
+		}
+		if decl, ok := file.Decls[name]; ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+func sortedImports(file *internal.File) []*internal.Import {
+	imps := make([]*internal.Import, 0, len(file.Imports))
+	for _, imp := range file.Imports {
+		imps = append(imps, imp)
+	}
+	slices.SortFunc(imps, func(a, b *internal.Import) int {
+		return cmp.Compare(a.UID(), b.UID())
+	})
+	return imps
+}
+
+func (builder *PrimitiveBuilder) addReference(node *Reference) error {
+	file, ok := builder.filesByUID[node.AbsPath]
+	if !ok {
+		return fmt.Errorf("add reference: no file defined: %s", node.AbsPath)
+	}
+	var imp *internal.Import
+	for _, fileImp := range file.Imports {
+		if fileImp.Path != node.ImportPath {
+			continue
+		}
+		imp = fileImp
+		break
+	}
+	if imp == nil {
+		return fmt.Errorf("add reference: no import defined: %s \"%s\"", node.DeclName, node.ImportPath)
+	}
+
+	declFile, ok := builder.filesByUID[node.DeclAbsPath]
+	if !ok || declFile.IsStub {
+		// declared outside the module, resolve it by name like a selector
+		return referenceDeclByName(imp, node.DeclName, node.Position)
+	}
+	decl, ok := declFile.Decls[node.DeclName]
+	if !ok {
+		return fmt.Errorf("add reference: missing type declaration: %s in %s", node.DeclName, node.DeclAbsPath)
+	}
+	imp.ReferencedTypes[decl.UID()] = decl
+	imp.Uses = append(imp.Uses, &internal.Use{Decl: decl, Pos: node.Position})
+	return nil
+}
+
+// referenceDeclByName records a reference, through imp, to the declaration
+// named name used at pos. The declaration is added to the stub file of the
+// imported package if it has not been declared yet.
+func referenceDeclByName(imp *internal.Import, name string, pos token.Position) error {
 	decl := &internal.Decl{
-		Name: node.Sel.String(),
+		Name: name,
 	}
 
-	if _, ok := imp.ReferencedTypes[decl.Name]; ok {
+	if referenced, ok := imp.ReferencedTypes[decl.Name]; ok {
 		// type already registered
+		imp.Uses = append(imp.Uses, &internal.Use{Decl: referenced, Pos: pos})
 		return nil
 	}
 
 	if imp.Package == nil {
-		return fmt.Errorf("add selector expr: no package defined here: %s", node.Sel.String())
+		return fmt.Errorf("add selector expr: no package defined here: %s", name)
 	}
 
 	// attempt to find file where declaration is defined
@@ -363,7 +705,8 @@ func (builder *PrimitiveBuilder) addSelectorExpr(node *SelectorExpr) error {
 		if !file.HasDecl(decl) {
 			continue
 		}
-		decl.File = file
+		// reference the declaration itself so it carries its position
+		decl = file.Decls[decl.UID()]
 		foundDecl = true
 		break
 	}
@@ -371,7 +714,7 @@ func (builder *PrimitiveBuilder) addSelectorExpr(node *SelectorExpr) error {
 	// if not file is found, attempt to add to a stub file
 	if !foundDecl {
 		if stubFile == nil {
-			return fmt.Errorf("add selector expr: no stub file defined: %s", node.Sel.String())
+			return fmt.Errorf("add selector expr: no stub file defined: %s", name)
 		}
 		stubDecl, isDeclInStub := stubFile.Decls[decl.UID()]
 		if isDeclInStub {
@@ -385,10 +728,11 @@ func (builder *PrimitiveBuilder) addSelectorExpr(node *SelectorExpr) error {
 	}
 
 	if decl.File == nil {
-		return fmt.Errorf("add selector expr: missing type declaration: %s", node.Sel.String())
+		return fmt.Errorf("add selector expr: missing type declaration: %s", name)
 	}
 
 	imp.ReferencedTypes[decl.Name] = decl
+	imp.Uses = append(imp.Uses, &internal.Use{Decl: decl, Pos: pos})
 	return nil
 }
 
@@ -421,6 +765,13 @@ func (builder *PrimitiveBuilder) fixupStubDecl(newDecl *internal.Decl) *internal
 	return newDecl
 }
 
+// isRedeclarable reports whether name may be declared more than once, the
+// blank identifier and init functions cannot be referenced so only the first
+// declaration is kept.
+func isRedeclarable(name, receiverName string) bool {
+	return name == "_" || (name == "init" && receiverName == "")
+}
+
 func buildPackage(
 	modulePath,
 	moduleRootDir,
@@ -461,6 +812,7 @@ func copyPackage(to, from *internal.Package) {
 		}
 	}
 	to.IsStub = from.IsStub
+	to.IsExternalTest = from.IsExternalTest
 	to.InImportCycle = from.InImportCycle
 }
 
@@ -468,52 +820,5 @@ func copyDeclaration(to, from *internal.Decl) {
 	to.File = from.File
 	to.ReceiverDecl = from.ReceiverDecl
 	to.Name = from.Name
-}
-
-type FileStack struct {
-	indexByUID map[string]int
-	stack      []*internal.File
-}
-
-func NewFileStack() *FileStack {
-	return &FileStack{
-		indexByUID: make(map[string]int),
-		stack:      make([]*internal.File, 0),
-	}
-}
-
-func (s *FileStack) Push(f *internal.File) {
-	s.stack = append(s.stack, f)
-	s.indexByUID[f.UID()] = len(s.stack) - 1
-}
-
-func (s *FileStack) Pop() {
-	delete(s.indexByUID, s.stack[len(s.stack)-1].UID())
-	s.stack = s.stack[0 : len(s.stack)-1]
-}
-
-func (s *FileStack) Top() *internal.File {
-	if len(s.stack) == 0 {
-		return nil
-	}
-	return s.stack[len(s.stack)-1]
-}
-
-func (s *FileStack) At(i int) *internal.File {
-	if len(s.stack) == 0 {
-		return nil
-	}
-	if i > (len(s.stack) - 1) {
-		return nil
-	}
-	return s.stack[i]
-}
-
-func (s *FileStack) Contains(f *internal.File) bool {
-	_, ok := s.indexByUID[f.UID()]
-	return ok
-}
-
-func (s *FileStack) Len() int {
-	return len(s.stack)
+	to.Pos = from.Pos
 }
@@ -152,8 +152,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -385,8 +386,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -648,8 +650,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -913,8 +916,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -1178,8 +1182,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -1491,8 +1496,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -1763,8 +1769,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -2088,8 +2095,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -2373,8 +2381,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -2759,8 +2768,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -3097,8 +3107,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -3471,8 +3482,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -3613,3 +3625,473 @@ func(path string, n *Node) {
 		}
 	}
 }
+
+func TestPrimitiveBuilder_AddNode_TestFiles(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			{
+				"tests",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+func AFn() {}
+`,
+							},
+							{
+								"a_test.go",
+								nil,
+								"a",
+								`
+package a
+
+func TestAFn() {
+	AFn()
+}
+`,
+							},
+							{
+								"external_test.go",
+								nil,
+								"a_test",
+								`
+package a_test
+
+import "example.com/tests/a"
+
+func TestExternalAFn() {
+	a.AFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	type expectedFile struct {
+		pkgUID string
+		kind   internal.FileKind
+	}
+	expectedFilesByFileName := map[string]expectedFile{
+		"a.go": {
+			pkgUID: "example.com/tests/a",
+			kind:   internal.ProductionFile,
+		},
+		"a_test.go": {
+			pkgUID: "example.com/tests/a",
+			kind:   internal.InPackageTestFile,
+		},
+		"external_test.go": {
+			pkgUID: "example.com/tests/a_test",
+			kind:   internal.ExternalTestFile,
+		},
+	}
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		dirOut := make(chan string)
+		depVis, nodeOut := internalAST.NewDependencyVisitor()
+		builder := internalAST.NewPrimitiveBuilder("example.com", tmpDir+string(os.PathSeparator)+"testdata")
+
+		directoryPathsInOrder := []string{}
+		walkTree(
+			treeNode,
+			treeNode.name,
+			func(path string, n *Node) {
+				if n.entries == nil {
+					return
+				}
+				directoryPathsInOrder = append(
+					directoryPathsInOrder,
+					tmpDir+string(os.PathSeparator)+"testdata"+string(os.PathSeparator)+path,
+				)
+			},
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		go func() {
+			for _, dirPath := range directoryPathsInOrder {
+				dirOut <- dirPath
+			}
+			close(dirOut)
+		}()
+
+		go func() {
+			for {
+				select {
+				case dirPath, ok := <-dirOut:
+					if !ok {
+						depVis.Close()
+						return
+					}
+					fset := token.NewFileSet()
+					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
+					if err != nil {
+						cancel()
+						t.Errorf("%s: %s", testCase, err)
+						return
+					}
+
+					for _, pkg := range pkgs {
+						ast.Walk(depVis, pkg)
+					}
+
+				case <-ctx.Done():
+					depVis.Close()
+					return
+				}
+			}
+		}()
+
+		go func() {
+			for {
+				select {
+				case astNode, ok := <-nodeOut:
+					if !ok {
+						cancel()
+						return
+					}
+					err := builder.AddNode(astNode)
+					if err != nil {
+						cancel()
+						t.Error(err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		<-ctx.Done()
+
+		for _, file := range builder.Files() {
+			if file.IsStub {
+				continue
+			}
+			expected, ok := expectedFilesByFileName[file.FileName]
+			if !ok {
+				t.Errorf(
+					"%s: unexpected file: %s \"%s\"",
+					testCase,
+					file.FileName,
+					file.AbsPath,
+				)
+				continue
+			}
+			if file.Package.UID() != expected.pkgUID {
+				t.Errorf(
+					"%s: %s: expected package %s, got %s",
+					testCase,
+					file.FileName,
+					expected.pkgUID,
+					file.Package.UID(),
+				)
+			}
+			if file.Kind != expected.kind {
+				t.Errorf(
+					"%s: %s: expected kind %d, got %d",
+					testCase,
+					file.FileName,
+					expected.kind,
+					file.Kind,
+				)
+			}
+			delete(expectedFilesByFileName, file.FileName)
+		}
+
+		for fileName := range expectedFilesByFileName {
+			t.Errorf(
+				"%s: missing expected file: %s",
+				testCase,
+				fileName,
+			)
+		}
+	}
+}
+
+func TestPrimitiveBuilder_AddNode_Generics(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			{
+				"generics",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a_push.go",
+								nil,
+								"a",
+								`
+package a
+
+func (l *List[T]) Push(v T) {}
+`,
+							},
+							{
+								"list.go",
+								nil,
+								"a",
+								`
+package a
+
+type List[T any] struct{}
+
+func (m Map[K, V]) Get(k K) V { return m[k] }
+
+type Map[K comparable, V any] map[K]V
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/generics/a"
+
+var L = &a.List[int]{}
+
+var M = a.Map[string, int]{}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	type expectedMethod struct {
+		receiverName     string
+		receiverFileName string
+	}
+	expectedMethodsByUID := map[string]expectedMethod{
+		"List.Push": {
+			receiverName:     "List",
+			receiverFileName: "list.go",
+		},
+		"Map.Get": {
+			receiverName:     "Map",
+			receiverFileName: "list.go",
+		},
+	}
+	expectedReferencedFileNamesByUID := map[string]string{
+		"List": "list.go",
+		"Map":  "list.go",
+	}
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		dirOut := make(chan string)
+		depVis, nodeOut := internalAST.NewDependencyVisitor()
+		builder := internalAST.NewPrimitiveBuilder("example.com", tmpDir+string(os.PathSeparator)+"testdata")
+
+		directoryPathsInOrder := []string{}
+		walkTree(
+			treeNode,
+			treeNode.name,
+			func(path string, n *Node) {
+				if n.entries == nil {
+					return
+				}
+				directoryPathsInOrder = append(
+					directoryPathsInOrder,
+					tmpDir+string(os.PathSeparator)+"testdata"+string(os.PathSeparator)+path,
+				)
+			},
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		go func() {
+			for _, dirPath := range directoryPathsInOrder {
+				dirOut <- dirPath
+			}
+			close(dirOut)
+		}()
+
+		go func() {
+			for {
+				select {
+				case dirPath, ok := <-dirOut:
+					if !ok {
+						depVis.Close()
+						return
+					}
+					fset := token.NewFileSet()
+					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
+					if err != nil {
+						cancel()
+						t.Errorf("%s: %s", testCase, err)
+						return
+					}
+
+					for _, pkg := range pkgs {
+						ast.Walk(depVis, pkg)
+					}
+
+				case <-ctx.Done():
+					depVis.Close()
+					return
+				}
+			}
+		}()
+
+		go func() {
+			for {
+				select {
+				case astNode, ok := <-nodeOut:
+					if !ok {
+						cancel()
+						return
+					}
+					err := builder.AddNode(astNode)
+					if err != nil {
+						cancel()
+						t.Error(err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		<-ctx.Done()
+
+		for _, file := range builder.Files() {
+			if file.IsStub {
+				continue
+			}
+			for declUID, decl := range file.Decls {
+				expected, ok := expectedMethodsByUID[declUID]
+				if !ok {
+					continue
+				}
+				if decl.ReceiverDecl == nil {
+					t.Errorf("%s: %s: expected receiver declaration", testCase, declUID)
+					continue
+				}
+				if decl.ReceiverDecl.Name != expected.receiverName {
+					t.Errorf(
+						"%s: %s: expected receiver %s, got %s",
+						testCase,
+						declUID,
+						expected.receiverName,
+						decl.ReceiverDecl.Name,
+					)
+				}
+				if decl.ReceiverDecl.File.FileName != expected.receiverFileName {
+					t.Errorf(
+						"%s: %s: expected receiver in %s, got %s",
+						testCase,
+						declUID,
+						expected.receiverFileName,
+						decl.ReceiverDecl.File.FileName,
+					)
+				}
+				delete(expectedMethodsByUID, declUID)
+			}
+			if file.FileName != "b.go" {
+				continue
+			}
+			for _, imp := range file.Imports {
+				for declUID, decl := range imp.ReferencedTypes {
+					expected, ok := expectedReferencedFileNamesByUID[declUID]
+					if !ok {
+						t.Errorf("%s: unexpected reference: %s", testCase, declUID)
+						continue
+					}
+					if decl.File.FileName != expected {
+						t.Errorf(
+							"%s: %s: expected reference to %s, got %s",
+							testCase,
+							declUID,
+							expected,
+							decl.File.FileName,
+						)
+					}
+					delete(expectedReferencedFileNamesByUID, declUID)
+				}
+			}
+		}
+
+		for declUID := range expectedMethodsByUID {
+			t.Errorf("%s: missing expected method: %s", testCase, declUID)
+		}
+		for declUID := range expectedReferencedFileNamesByUID {
+			t.Errorf("%s: missing expected reference: %s", testCase, declUID)
+		}
+	}
+}
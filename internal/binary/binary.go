@@ -0,0 +1,93 @@
+package binary
+
+import (
+	"slices"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+// Reachable returns the packages of pkgs built into the binary of the main
+// package in the directory dir, in the order of pkgs, keeping only their
+// files the binary reaches. The non-test files of the main package are
+// reached, and from a reached file the files declaring the declarations it
+// references, or every non-test file of a package it imports without
+// referencing a declaration, e.g. for its side effects. A package is built
+// into the binary when a reached file imports it.
+//
+// The files which are not reached are removed from the returned packages and
+// their import cycles are marked up again through the given edges, so every
+// import cycle between them is an import cycle of the binary.
+func Reachable(pkgs []*internal.Package, dir string, edges config.Edges) ([]*internal.Package, error) {
+	var entry *internal.Package
+	for _, pkg := range pkgs {
+		if pkg.IsStub || pkg.IsExternalTest || pkg.DirName != dir {
+			continue
+		}
+		if pkg.Name != "main" {
+			return nil, &NotMainError{Dir: dir, Name: pkg.Name}
+		}
+		entry = pkg
+	}
+	if entry == nil {
+		return nil, &NotFoundError{Dir: dir}
+	}
+
+	analyzed := make(map[*internal.Package]struct{}, len(pkgs))
+	for _, pkg := range pkgs {
+		analyzed[pkg] = struct{}{}
+	}
+	g := graph.Files(pkgs)
+	reachedPkgs := map[*internal.Package]struct{}{entry: {}}
+	reachedFiles := make(map[*internal.File]struct{})
+	var queue []*internal.File
+	reach := func(file *internal.File) {
+		if _, ok := reachedFiles[file]; ok || file.IsTest() {
+			return
+		}
+		reachedFiles[file] = struct{}{}
+		queue = append(queue, file)
+	}
+	reachPackage := func(pkg *internal.Package) {
+		for _, file := range pkg.Files {
+			reach(file)
+		}
+	}
+
+	reachPackage(entry)
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		for _, e := range g.Out(g.FileNode(file)) {
+			reach(e.To.File)
+		}
+		for _, imp := range file.Imports {
+			if imp.Package == nil {
+				continue
+			}
+			if _, ok := analyzed[imp.Package]; !ok {
+				continue
+			}
+			reachedPkgs[imp.Package] = struct{}{}
+			if len(imp.ReferencedTypes) == 0 {
+				reachPackage(imp.Package)
+			}
+		}
+	}
+
+	reachable := slices.DeleteFunc(slices.Clone(pkgs), func(pkg *internal.Package) bool {
+		_, ok := reachedPkgs[pkg]
+		return !ok
+	})
+	for _, pkg := range reachable {
+		for uid, file := range pkg.Files {
+			if _, ok := reachedFiles[file]; !ok {
+				delete(pkg.Files, uid)
+			}
+		}
+	}
+	ast.MarkupImportCycles(reachable, edges)
+	return reachable, nil
+}
@@ -0,0 +1,174 @@
+package binary_test
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/binary"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+)
+
+func TestReachable(t *testing.T) {
+	moduleRoot := filepath.FromSlash("/tmp/m")
+	pkgs := make(map[string]*internal.Package)
+	var pkgList []*internal.Package
+	newPkg := func(dir, name string) *internal.Package {
+		pkg := &internal.Package{
+			DirName:    filepath.Join(moduleRoot, filepath.FromSlash(dir)),
+			ModulePath: "example.com/m",
+			ModuleRoot: moduleRoot,
+			Name:       name,
+			Files:      make(map[string]*internal.File),
+		}
+		pkgs[dir] = pkg
+		pkgList = append(pkgList, pkg)
+		return pkg
+	}
+	newFile := func(pkg *internal.Package, name string, kind internal.FileKind, imports ...string) {
+		file := &internal.File{
+			Package:  pkg,
+			FileName: name,
+			AbsPath:  filepath.Join(pkg.DirName, name),
+			Kind:     kind,
+			Imports:  make(map[string]*internal.Import),
+		}
+		for _, dir := range imports {
+			file.Imports[dir] = &internal.Import{
+				Name:    dir,
+				Path:    "example.com/m/" + dir,
+				Package: pkgs[dir],
+			}
+		}
+		pkg.Files[file.UID()] = file
+	}
+
+	newPkg("a", "a")
+	newPkg("b", "b")
+	newPkg("c", "c")
+	newPkg("d", "d")
+	newFile(pkgs["c"], "c.go", internal.ProductionFile)
+	newFile(pkgs["d"], "d.go", internal.ProductionFile)
+	newFile(pkgs["a"], "a.go", internal.ProductionFile, "c")
+	newFile(pkgs["a"], "a_test.go", internal.InPackageTestFile, "d")
+	newFile(pkgs["b"], "b.go", internal.ProductionFile)
+	newFile(newPkg("cmd/api", "main"), "main.go", internal.ProductionFile, "a")
+	newFile(newPkg("cmd/worker", "main"), "main.go", internal.ProductionFile, "b")
+
+	testCases := map[string][]string{
+		"cmd/api":    {"a", "c", "cmd/api"},
+		"cmd/worker": {"b", "cmd/worker"},
+	}
+	for dir, expected := range testCases {
+		reachable, err := binary.Reachable(pkgList, pkgs[dir].DirName, config.ImportEdges)
+		if err != nil {
+			t.Fatalf("%s: %s", dir, err)
+		}
+		var actual []string
+		for _, pkg := range reachable {
+			rel, err := filepath.Rel(moduleRoot, pkg.DirName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		slices.Sort(actual)
+		if !slices.Equal(expected, actual) {
+			t.Errorf("%s: expected %v, got %v", dir, expected, actual)
+		}
+	}
+
+	var notMainErr *binary.NotMainError
+	if _, err := binary.Reachable(pkgList, pkgs["a"].DirName, config.ImportEdges); !errors.As(err, &notMainErr) {
+		t.Errorf("a: expected NotMainError, got %v", err)
+	}
+	var notFoundErr *binary.NotFoundError
+	if _, err := binary.Reachable(pkgList, filepath.Join(moduleRoot, "e"), config.ImportEdges); !errors.As(err, &notFoundErr) {
+		t.Errorf("e: expected NotFoundError, got %v", err)
+	}
+}
+
+func TestReachable_Files(t *testing.T) {
+	moduleRoot := filepath.FromSlash("/tmp/m")
+	pkgs := make(map[string]*internal.Package)
+	var pkgList []*internal.Package
+	newPkg := func(dir, name string) *internal.Package {
+		pkg := &internal.Package{
+			DirName:    filepath.Join(moduleRoot, filepath.FromSlash(dir)),
+			ModulePath: "example.com/m",
+			ModuleRoot: moduleRoot,
+			Name:       name,
+			Files:      make(map[string]*internal.File),
+		}
+		pkgs[dir] = pkg
+		pkgList = append(pkgList, pkg)
+		return pkg
+	}
+	decls := make(map[string]*internal.Decl)
+	// newFile declares decl, e.g. a.AFn, and references the declarations of
+	// refs
+	newFile := func(pkg *internal.Package, name string, decl string, refs ...string) {
+		file := &internal.File{
+			Package:  pkg,
+			FileName: name,
+			AbsPath:  filepath.Join(pkg.DirName, name),
+			Kind:     internal.ProductionFile,
+			Imports:  make(map[string]*internal.Import),
+			Decls:    make(map[string]*internal.Decl),
+		}
+		if decl != "" {
+			d := &internal.Decl{File: file, Name: decl}
+			file.Decls[d.UID()] = d
+			decls[pkg.Name+"."+decl] = d
+		}
+		for _, ref := range refs {
+			d := decls[ref]
+			imp, ok := file.Imports[d.File.Package.Name]
+			if !ok {
+				imp = &internal.Import{
+					Name:            d.File.Package.Name,
+					Path:            "example.com/m/" + d.File.Package.Name,
+					Package:         d.File.Package,
+					ReferencedTypes: make(map[string]*internal.Decl),
+				}
+				file.Imports[imp.Name] = imp
+			}
+			imp.ReferencedTypes[d.UID()] = d
+		}
+		pkg.Files[file.UID()] = file
+	}
+
+	newPkg("a", "a")
+	newPkg("b", "b")
+	newFile(pkgs["a"], "a.go", "AFn")
+	newFile(pkgs["b"], "b.go", "BFn", "a.AFn")
+	// a -> b -> a only through unused.go
+	newFile(pkgs["a"], "unused.go", "Unused", "b.BFn")
+	newFile(newPkg("cmd/api", "main"), "main.go", "", "a.AFn")
+	ast.MarkupImportCycles(pkgList, config.DeclarationEdges)
+	if !pkgs["a"].InImportCycle {
+		t.Fatal("a: expected to be in an import cycle before filtering")
+	}
+
+	reachable, err := binary.Reachable(pkgList, pkgs["cmd/api"].DirName, config.DeclarationEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, pkg := range reachable {
+		for _, file := range pkg.Files {
+			actual = append(actual, file.ModuleRelativePath())
+		}
+		if pkg.InImportCycle {
+			t.Errorf("%s: expected not to be in an import cycle", pkg.ModuleRelativePath())
+		}
+	}
+	slices.Sort(actual)
+	expected := []string{filepath.FromSlash("a/a.go"), filepath.FromSlash("cmd/api/main.go")}
+	if !slices.Equal(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,20 @@
+package binary
+
+import "fmt"
+
+type NotFoundError struct {
+	Dir string
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("binary: no package analyzed in %s", err.Dir)
+}
+
+type NotMainError struct {
+	Dir  string
+	Name string
+}
+
+func (err *NotMainError) Error() string {
+	return fmt.Sprintf("binary: %s is package %s, not a main package", err.Dir, err.Name)
+}
@@ -0,0 +1,253 @@
+// Package cache stores the dependency facts of files on disk so unchanged
+// files are not parsed again. Facts are keyed by the content of the file,
+// the build configuration, and the version of goimportcycle.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+)
+
+// formatVersion is incremented whenever the facts extracted from a file
+// change, invalidating every cached file.
+const formatVersion = 1
+
+const modulePath = "github.com/samlitowitz/goimportcycle"
+
+const (
+	// trimInterval is how often unused entries are removed.
+	trimInterval = 24 * time.Hour
+	// trimLimit is how long an entry is kept without being used.
+	trimLimit = 5 * 24 * time.Hour
+	// touchInterval is how often the modification time of a used entry is
+	// updated, marking it used.
+	touchInterval = time.Hour
+)
+
+// Cache is a directory of cached facts.
+type Cache struct {
+	dir     string
+	version string
+}
+
+// Key identifies the facts of a file.
+type Key [sha256.Size]byte
+
+// Open opens the cache in dir, goimportcycle in the user cache directory
+// when dir is empty, creating it if needed. Entries unused for 5 days are
+// removed once a day.
+func Open(dir string) (*Cache, error) {
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userCacheDir, "goimportcycle")
+	}
+	version, err := toolVersion()
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:     dir,
+		version: version,
+	}
+	c.trim(time.Now())
+	return c, nil
+}
+
+// Cacheable reports whether the facts of a file with content can be cached.
+// Positions are cached as offsets, so files with //line directives, which
+// change how offsets map to lines, are not.
+func Cacheable(content []byte) bool {
+	return !bytes.Contains(content, []byte("//line ")) && !bytes.Contains(content, []byte("/*line "))
+}
+
+// Key returns the key of the file at absPath with content, selected by
+// buildContext.
+func (c *Cache) Key(buildContext *build.Context, absPath string, content []byte) Key {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %s\n", c.version)
+	fmt.Fprintf(h, "goos %s\n", buildContext.GOOS)
+	fmt.Fprintf(h, "goarch %s\n", buildContext.GOARCH)
+	fmt.Fprintf(h, "compiler %s\n", buildContext.Compiler)
+	fmt.Fprintf(h, "cgo %t\n", buildContext.CgoEnabled)
+	fmt.Fprintf(h, "tags %s\n", strings.Join(buildContext.BuildTags, ","))
+	fmt.Fprintf(h, "tooltags %s\n", strings.Join(buildContext.ToolTags, ","))
+	fmt.Fprintf(h, "releasetags %s\n", strings.Join(buildContext.ReleaseTags, ","))
+	fmt.Fprintf(h, "allfiles %t\n", buildContext.UseAllFiles)
+	fmt.Fprintf(h, "file %s %d\n", absPath, len(content))
+	h.Write(content)
+
+	var key Key
+	h.Sum(key[:0])
+	return key
+}
+
+func (c *Cache) path(key Key) string {
+	name := hex.EncodeToString(key[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// Get returns the facts cached for key, ok is false if there are none or the
+// package names they were extracted with no longer resolve to the same names.
+func (c *Cache) Get(key Key, resolver internalAST.PackageNameResolver) (*Facts, bool) {
+	path := c.path(key)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var facts Facts
+	err = gob.NewDecoder(f).Decode(&facts)
+	if err != nil {
+		return nil, false
+	}
+	if !internalAST.Resolves(resolver, facts.PackageNames) {
+		return nil, false
+	}
+	c.touch(f, path, time.Now())
+	return &facts, true
+}
+
+// Put caches facts for key.
+func (c *Cache) Put(key Key, facts *Facts) error {
+	path := c.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return err
+	}
+	// write a temporary file and rename it so a concurrent Get never reads
+	// a partial entry
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(facts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// touch marks the entry at path used, at most once per touchInterval.
+func (c *Cache) touch(f *os.File, path string, now time.Time) {
+	info, err := f.Stat()
+	if err != nil || now.Sub(info.ModTime()) < touchInterval {
+		return
+	}
+	os.Chtimes(path, now, now)
+}
+
+// trim removes the entries unused for trimLimit, at most once per
+// trimInterval.
+func (c *Cache) trim(now time.Time) {
+	marker := filepath.Join(c.dir, "trim.txt")
+	if info, err := os.Stat(marker); err == nil && now.Sub(info.ModTime()) < trimInterval {
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0666); err != nil {
+		return
+	}
+
+	subdirs, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, subdir := range subdirs {
+		if !subdir.IsDir() || len(subdir.Name()) != 2 {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(c.dir, subdir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || now.Sub(info.ModTime()) < trimLimit {
+				continue
+			}
+			os.Remove(filepath.Join(c.dir, subdir.Name(), entry.Name()))
+		}
+	}
+}
+
+var (
+	toolVersionOnce sync.Once
+	toolVersionStr  string
+	toolVersionErr  error
+)
+
+// toolVersion identifies the build of goimportcycle the facts are extracted
+// by, the module version of released builds and the hash of the executable
+// otherwise.
+func toolVersion() (string, error) {
+	toolVersionOnce.Do(func() {
+		toolVersionStr, toolVersionErr = readToolVersion()
+	})
+	return toolVersionStr, toolVersionErr
+}
+
+func readToolVersion() (string, error) {
+	version := fmt.Sprintf("%d %s", formatVersion, runtime.Version())
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		mod := &info.Main
+		if mod.Path != modulePath {
+			mod = nil
+			for _, dep := range info.Deps {
+				if dep.Path == modulePath && dep.Replace == nil {
+					mod = dep
+					break
+				}
+			}
+		}
+		if mod != nil && mod.Version != "" && mod.Version != "(devel)" {
+			return version + " " + mod.Version + " " + mod.Sum, nil
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(executable)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return version + " " + hex.EncodeToString(h.Sum(nil)), nil
+}
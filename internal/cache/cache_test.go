@@ -0,0 +1,194 @@
+package cache_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/cache"
+	"github.com/samlitowitz/goimportcycle/internal/pkgname"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const src = `package a
+
+import (
+	"fmt"
+	_ "embed"
+	. "example.com/m/dot"
+	y "gopkg.in/yaml.v3"
+	_ "unsafe"
+)
+
+//go:linkname now time.now
+func now() (int64, int32)
+
+type T struct{}
+
+func (t *T) M() { fmt.Println(y.Marshal) }
+
+const (
+	A, B = 1, 2
+)
+
+var V = Dotted
+`
+
+type resolverFunc func(importPath string) string
+
+func (fn resolverFunc) PackageName(importPath string) string {
+	return fn(importPath)
+}
+
+// describe lists the nodes with the positions the builder reads.
+func describe(fset *token.FileSet, nodes []ast.Node) []string {
+	pos := func(pos token.Pos) string {
+		return fset.Position(pos).String()
+	}
+	var described []string
+	for _, node := range nodes {
+		var desc string
+		switch node := node.(type) {
+		case *internalAST.File:
+			desc = fmt.Sprintf("file %s %s %s %s", node.AbsPath, node.DirName, node.Name.Name, pos(node.Pos()))
+		case *internalAST.ImportSpec:
+			desc = fmt.Sprintf("import %s %s %t %s %s", node.Name, node.Path.Value, node.IsAliased, node.Alias, pos(node.Path.Pos()))
+			if node.IsAliased {
+				desc += " " + pos(node.Name.Pos())
+			}
+		case *internalAST.FuncDecl:
+			desc = fmt.Sprintf("func %s %s %t %s", node.QualifiedName, node.ReceiverName, node.IsReceiver(), pos(node.Name.Pos()))
+		case *ast.GenDecl:
+			desc = "decl " + node.Tok.String()
+			for _, spec := range node.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					desc += fmt.Sprintf(" type %s %s", spec.Name, pos(spec.Name.Pos()))
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						desc += fmt.Sprintf(" value %s %s", name, pos(name.Pos()))
+					}
+				}
+			}
+		case *internalAST.SelectorExpr:
+			desc = fmt.Sprintf("selector %s.%s %s", node.ImportName, node.Sel, pos(node.Sel.Pos()))
+		case *internalAST.Ident:
+			desc = fmt.Sprintf("ident %s %s", node.Name, pos(node.Pos()))
+		case *internalAST.Linkname:
+			desc = fmt.Sprintf("linkname %s %s %s %s %s", node.LocalName, node.ImportPath, node.PackageName, node.Name, pos(node.Pos()))
+		default:
+			desc = fmt.Sprintf("unexpected %T", node)
+		}
+		described = append(described, desc)
+	}
+	return described
+}
+
+func TestFacts_Decode(t *testing.T) {
+	absPath := "/tmp/m/a/a.go"
+	resolver := resolverFunc(pkgname.AssumedName)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, absPath, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := describe(fset, internalAST.ExtractFile(resolver, fset, absPath, file))
+
+	facts := cache.ExtractFile(resolver, fset, absPath, file)
+	if !facts.Match {
+		t.Error("expected extracted facts to match")
+	}
+	expectedNames := map[string]string{
+		"fmt":               "fmt",
+		"embed":             "embed",
+		"example.com/m/dot": "dot",
+		"gopkg.in/yaml.v3":  "yaml",
+		"unsafe":            "unsafe",
+		"time":              "time",
+	}
+	if !cmp.Equal(expectedNames, facts.PackageNames) {
+		t.Error(cmp.Diff(expectedNames, facts.PackageNames))
+	}
+
+	// decoded into another file set, after another file
+	decodeFset := token.NewFileSet()
+	decodeFset.AddFile("/tmp/m/a/other.go", -1, 100)
+	actual := describe(decodeFset, facts.Decode(decodeFset, absPath, []byte(src)))
+	if !cmp.Equal(expected, actual) {
+		t.Error(cmp.Diff(expected, actual))
+	}
+}
+
+func TestCache_GetPut(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	absPath := "/tmp/m/a/a.go"
+	resolver := resolverFunc(pkgname.AssumedName)
+	linux := &build.Context{GOOS: "linux", GOARCH: "amd64", Compiler: "gc"}
+
+	key := c.Key(linux, absPath, []byte(src))
+	if _, ok := c.Get(key, resolver); ok {
+		t.Fatal("expected an empty cache to miss")
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, absPath, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	facts := cache.ExtractFile(resolver, fset, absPath, file)
+	if err := c.Put(key, facts); err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := c.Get(key, resolver)
+	if !ok {
+		t.Fatal("expected the cached facts")
+	}
+	if !cmp.Equal(facts, cached) {
+		t.Error(cmp.Diff(facts, cached))
+	}
+
+	renamed := resolverFunc(func(importPath string) string {
+		if importPath == "gopkg.in/yaml.v3" {
+			return "yamlv3"
+		}
+		return pkgname.AssumedName(importPath)
+	})
+	if _, ok := c.Get(key, renamed); ok {
+		t.Error("expected a miss once an imported package is renamed")
+	}
+
+	tagged := *linux
+	tagged.BuildTags = []string{"integration"}
+	windows := *linux
+	windows.GOOS = "windows"
+	keys := map[string]cache.Key{
+		"build tags": c.Key(&tagged, absPath, []byte(src)),
+		"GOOS":       c.Key(&windows, absPath, []byte(src)),
+		"path":       c.Key(linux, "/tmp/m/b/a.go", []byte(src)),
+		"content":    c.Key(linux, absPath, []byte(strings.Replace(src, "V", "W", 1))),
+	}
+	for changed, changedKey := range keys {
+		if changedKey == key {
+			t.Errorf("expected another key when the %s change", changed)
+		}
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	if !cache.Cacheable([]byte(src)) {
+		t.Error("expected a file without //line directives to be cacheable")
+	}
+	if cache.Cacheable([]byte("package a\n\n//line a.y:10\nvar V int\n")) {
+		t.Error("expected a file with a //line directive not to be cacheable")
+	}
+}
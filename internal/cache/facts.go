@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+)
+
+type kind int
+
+const (
+	fileKind kind = iota
+	importKind
+	funcKind
+	genDeclKind
+	selectorKind
+	identKind
+	linknameKind
+)
+
+// Facts are the nodes a DependencyVisitor emits for a single file, stored
+// without the syntax tree of the file. Positions are byte offsets in the
+// file.
+type Facts struct {
+	// Match is false when the file is excluded by its build constraints,
+	// there are no nodes then.
+	Match bool
+	// PackageNames are the names resolved for the imports and
+	// //go:linkname directives of the file, by import path. The facts are
+	// stale once one of them resolves to another name.
+	PackageNames map[string]string
+	Nodes        []Node
+}
+
+// Node is a node emitted by the DependencyVisitor, the fields used depend
+// on its kind.
+type Node struct {
+	Kind kind
+	// Name is the package clause of a file, the name of an import, function,
+	// or identifier, and the selected name of a selector expression.
+	Name Ident
+	// Path is the unquoted path of an import, and the import name of a
+	// selector expression.
+	Path Ident
+
+	IsAliased bool
+	Alias     string
+
+	IsReceiver    bool
+	ReceiverName  string
+	QualifiedName string
+
+	// Tok and Specs are the token and the names of each spec of a const,
+	// type, or var declaration.
+	Tok   token.Token
+	Specs [][]Ident
+
+	// Comment is the //go:linkname directive, LocalName, ImportPath,
+	// PackageName, and DeclName its arguments.
+	Comment     Ident
+	LocalName   string
+	ImportPath  string
+	PackageName string
+	DeclName    string
+}
+
+// Ident is a name and its offset in the file, -1 if it has no position.
+type Ident struct {
+	Name   string
+	Offset int
+}
+
+// ExtractFile returns the facts of file, parsed with fset from the file at
+// absPath, see internalAST.ExtractFile.
+func ExtractFile(resolver internalAST.PackageNameResolver, fset *token.FileSet, absPath string, file *ast.File) *Facts {
+	recorder := internalAST.NewRecordingResolver(resolver)
+	nodes := internalAST.ExtractFile(recorder, fset, absPath, file)
+	facts := &Facts{
+		Match:        true,
+		PackageNames: recorder.Names(),
+		Nodes:        make([]Node, 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		if fact, ok := newNode(fset, node); ok {
+			facts.Nodes = append(facts.Nodes, fact)
+		}
+	}
+	return facts
+}
+
+func newNode(fset *token.FileSet, node ast.Node) (Node, bool) {
+	ident := func(name string, pos token.Pos) Ident {
+		if !pos.IsValid() {
+			return Ident{Name: name, Offset: -1}
+		}
+		return Ident{Name: name, Offset: fset.PositionFor(pos, false).Offset}
+	}
+	optionalIdent := func(node *ast.Ident) Ident {
+		if node == nil {
+			return Ident{Offset: -1}
+		}
+		return ident(node.Name, node.NamePos)
+	}
+
+	switch node := node.(type) {
+	case *internalAST.File:
+		return Node{
+			Kind: fileKind,
+			Name: ident(node.Name.Name, node.Package),
+		}, true
+
+	case *internalAST.ImportSpec:
+		return Node{
+			Kind:      importKind,
+			Name:      optionalIdent(node.Name),
+			Path:      ident(node.Path.Value, node.Path.ValuePos),
+			IsAliased: node.IsAliased,
+			Alias:     node.Alias,
+		}, true
+
+	case *internalAST.FuncDecl:
+		return Node{
+			Kind:          funcKind,
+			Name:          ident(node.Name.Name, node.Name.NamePos),
+			IsReceiver:    node.IsReceiver(),
+			ReceiverName:  node.ReceiverName,
+			QualifiedName: node.QualifiedName,
+		}, true
+
+	case *ast.GenDecl:
+		fact := Node{
+			Kind:  genDeclKind,
+			Tok:   node.Tok,
+			Specs: make([][]Ident, 0, len(node.Specs)),
+		}
+		for _, spec := range node.Specs {
+			var names []Ident
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, ident(spec.Name.Name, spec.Name.NamePos))
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					names = append(names, ident(name.Name, name.NamePos))
+				}
+			}
+			fact.Specs = append(fact.Specs, names)
+		}
+		return fact, true
+
+	case *internalAST.SelectorExpr:
+		return Node{
+			Kind: selectorKind,
+			Name: ident(node.Sel.Name, node.Sel.NamePos),
+			Path: ident(node.ImportName, node.X.Pos()),
+		}, true
+
+	case *internalAST.Ident:
+		return Node{
+			Kind: identKind,
+			Name: ident(node.Name, node.NamePos),
+		}, true
+
+	case *internalAST.Linkname:
+		return Node{
+			Kind:        linknameKind,
+			Comment:     ident(node.Text, node.Slash),
+			LocalName:   node.LocalName,
+			ImportPath:  node.ImportPath,
+			PackageName: node.PackageName,
+			DeclName:    node.Name,
+		}, true
+	}
+	return Node{}, false
+}
+
+// Decode returns the nodes of the facts positioned in fset, to which the file
+// at absPath with content is added. The first node is the *File node.
+func (facts *Facts) Decode(fset *token.FileSet, absPath string, content []byte) []ast.Node {
+	tokFile := fset.AddFile(absPath, -1, len(content))
+	tokFile.SetLinesForContent(content)
+	pos := func(offset int) token.Pos {
+		if offset < 0 || offset > len(content) {
+			return token.NoPos
+		}
+		return tokFile.Pos(offset)
+	}
+	ident := func(ident Ident) *ast.Ident {
+		return &ast.Ident{NamePos: pos(ident.Offset), Name: ident.Name}
+	}
+
+	nodes := make([]ast.Node, 0, len(facts.Nodes))
+	for _, fact := range facts.Nodes {
+		switch fact.Kind {
+		case fileKind:
+			nodes = append(nodes, &internalAST.File{
+				File: &ast.File{
+					Package: pos(fact.Name.Offset),
+					Name:    ident(fact.Name),
+				},
+				AbsPath: absPath,
+				DirName: filepath.Dir(absPath),
+			})
+
+		case importKind:
+			spec := &ast.ImportSpec{
+				Path: &ast.BasicLit{
+					ValuePos: pos(fact.Path.Offset),
+					Kind:     token.STRING,
+					Value:    fact.Path.Name,
+				},
+			}
+			if fact.Name.Name != "" {
+				spec.Name = ident(fact.Name)
+			}
+			nodes = append(nodes, &internalAST.ImportSpec{
+				ImportSpec: spec,
+				IsAliased:  fact.IsAliased,
+				Alias:      fact.Alias,
+			})
+
+		case funcKind:
+			decl := &ast.FuncDecl{
+				Name: ident(fact.Name),
+			}
+			if fact.IsReceiver {
+				decl.Recv = &ast.FieldList{}
+			}
+			nodes = append(nodes, &internalAST.FuncDecl{
+				FuncDecl:      decl,
+				ReceiverName:  fact.ReceiverName,
+				QualifiedName: fact.QualifiedName,
+			})
+
+		case genDeclKind:
+			decl := &ast.GenDecl{
+				Tok:   fact.Tok,
+				Specs: make([]ast.Spec, 0, len(fact.Specs)),
+			}
+			for _, names := range fact.Specs {
+				if fact.Tok == token.TYPE && len(names) == 1 {
+					decl.Specs = append(decl.Specs, &ast.TypeSpec{Name: ident(names[0])})
+					continue
+				}
+				spec := &ast.ValueSpec{Names: make([]*ast.Ident, 0, len(names))}
+				for _, name := range names {
+					spec.Names = append(spec.Names, ident(name))
+				}
+				decl.Specs = append(decl.Specs, spec)
+			}
+			nodes = append(nodes, decl)
+
+		case selectorKind:
+			nodes = append(nodes, &internalAST.SelectorExpr{
+				SelectorExpr: &ast.SelectorExpr{
+					X:   ident(fact.Path),
+					Sel: ident(fact.Name),
+				},
+				ImportName: fact.Path.Name,
+			})
+
+		case identKind:
+			nodes = append(nodes, &internalAST.Ident{
+				Ident: ident(fact.Name),
+			})
+
+		case linknameKind:
+			nodes = append(nodes, &internalAST.Linkname{
+				Comment: &ast.Comment{
+					Slash: pos(fact.Comment.Offset),
+					Text:  fact.Comment.Name,
+				},
+				LocalName:   fact.LocalName,
+				ImportPath:  fact.ImportPath,
+				PackageName: fact.PackageName,
+				Name:        fact.DeclName,
+			})
+		}
+	}
+	return nodes
+}
@@ -19,11 +19,56 @@
 	PackageResolution Resolution = iota
 )
 
+// Edges selects which imports are edges of the package graph.
+type Edges int
+
+const (
+	// DeclarationEdges are imports with at least one referenced declaration.
+	DeclarationEdges Edges = iota
+	// ImportEdges are all imports, the same as the compiler.
+	ImportEdges Edges = iota
+)
+
+const DefaultMaxCycles = 1000
+
 type Config struct {
 	Palette *inColor.Palette
 
 	Resolution Resolution
-	Debug      *log.Logger
+	Edges      Edges
+	// MaxCycles limits how many import cycles are enumerated, zero or less
+	// enumerates every cycle.
+	MaxCycles int
+	// Tests includes _test.go files and external _test packages.
+	Tests bool
+	// Typecheck resolves references with go/types instead of import names.
+	Typecheck bool
+	// Include limits the analyzed directories to those matching one of the
+	// glob patterns, relative to the module root, and the directories below
+	// them.
+	Include []string
+	// Exclude skips the directories matching one of the glob patterns,
+	// relative to the module root.
+	Exclude []string
+	// GitIgnore skips the directories ignored by .gitignore files.
+	GitIgnore bool
+	// Binary limits the output to the packages and files reachable from the
+	// main package in this directory, relative to the module root.
+	Binary string
+	// KeepGoing skips the directories, files, and declarations which cannot
+	// be analyzed instead of stopping, the analysis lists them as
+	// diagnostics.
+	KeepGoing bool
+	// NoCache parses every file instead of reading the facts of unchanged
+	// files from the cache.
+	NoCache bool
+	// CacheDir is the directory of the cache, goimportcycle in the user
+	// cache directory when empty.
+	CacheDir string
+	// LowMemory extracts the facts of each file and drops its syntax tree
+	// right after parsing it, see the Memory section of the README.
+	LowMemory bool
+	Debug     *log.Logger
 }
 
 type ExternalPalette struct {
@@ -35,7 +80,19 @@ type ExternalPalette struct {
 }
 
 type externalConfig struct {
-	Resolution string `yaml:"resolution,omitempty"`
+	Resolution string   `yaml:"resolution,omitempty"`
+	Edges      string   `yaml:"edges,omitempty"`
+	MaxCycles  *int     `yaml:"maxCycles,omitempty"`
+	Tests      bool     `yaml:"tests,omitempty"`
+	Typecheck  bool     `yaml:"typecheck,omitempty"`
+	Include    []string `yaml:"include,omitempty"`
+	Exclude    []string `yaml:"exclude,omitempty"`
+	GitIgnore  bool     `yaml:"gitignore,omitempty"`
+	Binary     string   `yaml:"binary,omitempty"`
+	KeepGoing  bool     `yaml:"keepGoing,omitempty"`
+	NoCache    bool     `yaml:"noCache,omitempty"`
+	CacheDir   string   `yaml:"cacheDir,omitempty"`
+	LowMemory  bool     `yaml:"lowMemory,omitempty"`
 	Palette    *struct {
 		Base  *ExternalPalette `yaml:"base,omitempty"`
 		Cycle *ExternalPalette `yaml:"cycle,omitempty"`
@@ -46,6 +103,8 @@ func Default() *Config {
 	return &Config{
 		Palette:    inColor.Default,
 		Resolution: FileResolution,
+		Edges:      DeclarationEdges,
+		MaxCycles:  DefaultMaxCycles,
 		Debug:      log.New(io.Discard, "Debug: ", log.LstdFlags),
 	}
 }
@@ -91,6 +150,50 @@ func fromExternalConfig(to *Config, from *externalConfig) error {
 		)
 	}
 
+	// edges
+	switch from.Edges {
+	case "":
+	case "declarations":
+		to.Edges = DeclarationEdges
+	case "imports":
+		to.Edges = ImportEdges
+
+	default:
+		return fmt.Errorf(
+			"invalid edges %s, must be one of ['declarations', 'imports']",
+			from.Edges,
+		)
+	}
+
+	// max cycles
+	if from.MaxCycles != nil {
+		to.MaxCycles = *from.MaxCycles
+	}
+
+	// tests
+	to.Tests = from.Tests
+
+	// typecheck
+	to.Typecheck = from.Typecheck
+
+	// directories
+	to.Include = from.Include
+	to.Exclude = from.Exclude
+	to.GitIgnore = from.GitIgnore
+
+	// binary
+	to.Binary = from.Binary
+
+	// keep going
+	to.KeepGoing = from.KeepGoing
+
+	// cache
+	to.NoCache = from.NoCache
+	to.CacheDir = from.CacheDir
+
+	// memory
+	to.LowMemory = from.LowMemory
+
 	// palette
 	if from.Palette == nil {
 		return nil
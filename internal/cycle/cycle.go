@@ -0,0 +1,128 @@
+package cycle
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+// Hop is a single dependency in an import cycle. At package resolution From
+// and To are *internal.Package, at file resolution they are *internal.File.
+type Hop struct {
+	From, To Node
+
+	Decls []*internal.Decl
+	// TestOnly is set when every reference is made from a _test.go file.
+	TestOnly bool
+	// Blank is set when every import is a blank import, Decls is empty.
+	Blank bool
+	// Linkname is set when a reference is made by a //go:linkname directive
+	// rather than an import.
+	Linkname bool
+	// Provenance are the imports the hop is made of.
+	Provenance []*graph.Provenance
+}
+
+type Node interface {
+	UID() string
+	ModuleRelativePath() string
+}
+
+type Cycle struct {
+	Hops []*Hop
+}
+
+// TestOnly reports whether the cycle only exists when _test.go files are
+// included.
+func (c Cycle) TestOnly() bool {
+	for _, hop := range c.Hops {
+		if hop.TestOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// DeclarationLevel reports whether every hop of the cycle references a
+// declaration. A cycle with a hop made by imports alone, e.g. a blank
+// import, can only be broken by removing the import.
+func (c Cycle) DeclarationLevel() bool {
+	for _, hop := range c.Hops {
+		if len(hop.Decls) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Path returns the module relative paths of every node in the cycle, ending
+// with the node the cycle started from.
+func (c Cycle) Path() []string {
+	if len(c.Hops) == 0 {
+		return nil
+	}
+	path := make([]string, 0, len(c.Hops)+1)
+	for _, hop := range c.Hops {
+		path = append(path, hop.From.ModuleRelativePath())
+	}
+	return append(path, c.Hops[0].From.ModuleRelativePath())
+}
+
+// Packages enumerates the elementary import cycles between packages. At most
+// limit cycles are returned, a limit of zero or less returns every cycle. The
+// second return value reports whether the limit was reached.
+func Packages(pkgs []*internal.Package, limit int) ([]*Cycle, bool) {
+	g := graph.Packages(pkgs, config.DeclarationEdges)
+	return enumerate(g, limit, func(node *graph.Node) Node { return node.Package })
+}
+
+// Imports enumerates the elementary import cycles between packages through
+// every import, i.e. the import cycles the compiler rejects, see Packages.
+func Imports(pkgs []*internal.Package, limit int) ([]*Cycle, bool) {
+	g := graph.Packages(pkgs, config.ImportEdges)
+	return enumerate(g, limit, func(node *graph.Node) Node { return node.Package })
+}
+
+// Files enumerates the elementary import cycles between the files of the
+// given packages, see Packages.
+func Files(pkgs []*internal.Package, limit int) ([]*Cycle, bool) {
+	g := graph.Files(pkgs)
+	return enumerate(g, limit, func(node *graph.Node) Node { return node.File })
+}
+
+func enumerate(g *graph.Graph, limit int, node func(node *graph.Node) Node) ([]*Cycle, bool) {
+	nodeCycles, limitReached := g.Cycles(limit)
+
+	cycles := make([]*Cycle, 0, len(nodeCycles))
+	for _, nodeCycle := range nodeCycles {
+		c := &Cycle{
+			Hops: make([]*Hop, 0, len(nodeCycle)),
+		}
+		for k, from := range nodeCycle {
+			to := nodeCycle[(k+1)%len(nodeCycle)]
+			c.Hops = append(c.Hops, newHop(g.Edge(from, to), node))
+		}
+		cycles = append(cycles, c)
+	}
+	slices.SortStableFunc(cycles, func(a, b *Cycle) int {
+		return cmp.Compare(len(a.Hops), len(b.Hops))
+	})
+
+	return cycles, limitReached
+}
+
+func newHop(e *graph.Edge, node func(node *graph.Node) Node) *Hop {
+	return &Hop{
+		From:     node(e.From),
+		To:       node(e.To),
+		Decls:    e.Decls(),
+		TestOnly: e.TestOnly(),
+		Blank:    e.Blank(),
+		Linkname: e.Linkname(),
+
+		Provenance: e.Provenance,
+	}
+}
@@ -0,0 +1,81 @@
+package cycle
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+// Suggestion is a hop whose imports, once removed, break import cycles.
+type Suggestion struct {
+	Hop *Hop
+	// Cycles is the number of elementary import cycles the hop is on.
+	Cycles int
+}
+
+// weight is the number of declarations to move to remove the hop, at least
+// one.
+func (s *Suggestion) weight() int {
+	if len(s.Hop.Decls) > 1 {
+		return len(s.Hop.Decls)
+	}
+	return 1
+}
+
+// SuggestPackages returns hops between packages whose removal breaks every
+// enumerated import cycle, see graph.FeedbackArcSet, weighted by the number
+// of declarations they reference. With config.ImportEdges every import is a
+// hop. Suggestions are ordered by the number of cycles they are on per
+// declaration, the most first. At most limit cycles are enumerated, a limit
+// of zero or less enumerates every cycle. The second return value is the
+// number of enumerated cycles, the third reports whether the limit was
+// reached, cycles which were not enumerated may then be left.
+func SuggestPackages(pkgs []*internal.Package, edges config.Edges, limit int) ([]*Suggestion, int, bool) {
+	g := graph.Packages(pkgs, edges)
+	return suggest(g, limit, func(node *graph.Node) Node { return node.Package })
+}
+
+// SuggestFiles returns hops between the files of the given packages whose
+// removal breaks every enumerated import cycle, see SuggestPackages.
+func SuggestFiles(pkgs []*internal.Package, limit int) ([]*Suggestion, int, bool) {
+	g := graph.Files(pkgs)
+	return suggest(g, limit, func(node *graph.Node) Node { return node.File })
+}
+
+func suggest(g *graph.Graph, limit int, node func(node *graph.Node) Node) ([]*Suggestion, int, bool) {
+	nodeCycles, limitReached := g.Cycles(limit)
+	onCycles := make(map[*graph.Edge]int)
+	for _, nodeCycle := range nodeCycles {
+		for k, from := range nodeCycle {
+			onCycles[g.Edge(from, nodeCycle[(k+1)%len(nodeCycle)])]++
+		}
+	}
+
+	edges := g.FeedbackEdges(nodeCycles, func(e *graph.Edge) int { return len(e.Decls()) })
+	suggestions := make([]*Suggestion, 0, len(edges))
+	for _, e := range edges {
+		suggestions = append(suggestions, &Suggestion{
+			Hop:    newHop(e, node),
+			Cycles: onCycles[e],
+		})
+	}
+	slices.SortStableFunc(suggestions, func(a, b *Suggestion) int {
+		// a.Cycles/a.weight() > b.Cycles/b.weight() first
+		if c := cmp.Compare(b.Cycles*a.weight(), a.Cycles*b.weight()); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.Hop.Decls), len(b.Hop.Decls)); c != 0 {
+			return c
+		}
+		return strings.Compare(hopPath(a.Hop), hopPath(b.Hop))
+	})
+	return suggestions, len(nodeCycles), limitReached
+}
+
+func hopPath(hop *Hop) string {
+	return hop.From.ModuleRelativePath() + " -> " + hop.To.ModuleRelativePath()
+}
@@ -1,34 +1,254 @@
 package directory
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
+// Emitter emits the directories of a module the way the go tool finds
+// packages, skipping directories starting with . or _, testdata and vendor
+// directories, and directories of nested modules. The root directory is
+// always emitted.
 type Emitter struct {
 	out chan<- string
+	ctx context.Context
+
+	root      string
+	include   []string
+	exclude   []string
+	gitIgnore bool
+
+	// ignores are the .gitignore files of the directories being walked
+	ignores map[string]*ignoreFile
+	// visited are the real paths of the emitted directories
+	visited map[string]struct{}
 }
 
 func NewEmitter() (*Emitter, <-chan string) {
 	output := make(chan string)
-	emitter := &Emitter{out: output}
+	emitter := &Emitter{
+		out:     output,
+		ctx:     context.Background(),
+		ignores: make(map[string]*ignoreFile),
+		visited: make(map[string]struct{}),
+	}
 
 	return emitter, output
 }
 
+// SetInclude limits the emitted directories to those matching one of
+// patterns and the directories below them, see SetExclude for the pattern
+// syntax.
+func (emitter *Emitter) SetInclude(patterns []string) error {
+	for _, pattern := range patterns {
+		if err := validateGlob(pattern); err != nil {
+			return fmt.Errorf("include: %w", err)
+		}
+	}
+	emitter.include = patterns
+	return nil
+}
+
+// SetExclude skips the directories, and everything below them, matching one
+// of patterns. Patterns are matched against the slash separated path relative
+// to the root, e.g. internal/gen, using path.Match syntax for each element
+// and ** for any number of elements.
+func (emitter *Emitter) SetExclude(patterns []string) error {
+	for _, pattern := range patterns {
+		if err := validateGlob(pattern); err != nil {
+			return fmt.Errorf("exclude: %w", err)
+		}
+	}
+	emitter.exclude = patterns
+	return nil
+}
+
+// SetGitIgnore skips the directories ignored by the .gitignore files found
+// while walking.
+func (emitter *Emitter) SetGitIgnore(gitIgnore bool) {
+	emitter.gitIgnore = gitIgnore
+}
+
+// Walk emits every directory below root as an absolute path. Symbolic links
+// to directories are followed, a directory reached twice, e.g. through a
+// symbolic link loop, is emitted once. Walking stops with the error of ctx
+// once it is done.
+func (emitter *Emitter) Walk(ctx context.Context, root string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	emitter.root = root
+	emitter.ctx = ctx
+	return filepath.WalkDir(root, emitter.WalkDirFunc)
+}
+
 func (emitter *Emitter) WalkDirFunc(path string, d fs.DirEntry, err error) error {
 	// any error stops all processing
 	if err != nil {
 		return err
 	}
+	if emitter.root == "" {
+		emitter.root = path
+	}
+
+	if d.Type()&fs.ModeSymlink != 0 {
+		return emitter.walkSymlink(path)
+	}
 
 	// skip individual files
 	if !d.IsDir() {
 		return nil
 	}
 
+	skip, err := emitter.skip(path)
+	if err != nil {
+		return err
+	}
+	if skip {
+		return fs.SkipDir
+	}
+
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := emitter.visited[realPath]; ok {
+		return fs.SkipDir
+	}
+	emitter.visited[realPath] = struct{}{}
+
+	if emitter.gitIgnore {
+		ignore, err := readIgnoreFile(path)
+		if err != nil {
+			return err
+		}
+		if ignore != nil {
+			emitter.ignores[path] = ignore
+		}
+	}
+
+	if !emitter.included(path) {
+		return nil
+	}
+
 	// emit directory path
-	emitter.out <- path
-	return nil
+	select {
+	case emitter.out <- path:
+		return nil
+	case <-emitter.ctx.Done():
+		return emitter.ctx.Err()
+	}
+}
+
+// walkSymlink walks the directory a symbolic link points at as if it was
+// below the link, links to files are skipped.
+func (emitter *Emitter) walkSymlink(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		// dangling link
+		return nil
+	}
+	if !fi.IsDir() {
+		return nil
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := emitter.visited[target]; ok {
+		return nil
+	}
+	return filepath.WalkDir(target, func(targetPath string, d fs.DirEntry, err error) error {
+		rel, relErr := filepath.Rel(target, targetPath)
+		if relErr != nil {
+			return relErr
+		}
+		if d != nil && targetPath == target {
+			// the target is walked under the name of the link
+			d = fs.FileInfoToDirEntry(fi)
+		}
+		return emitter.WalkDirFunc(filepath.Join(path, rel), d, err)
+	})
+}
+
+// skip reports whether the directory at path, and everything below it, is
+// not part of the module.
+func (emitter *Emitter) skip(path string) (bool, error) {
+	if path == emitter.root {
+		return false, nil
+	}
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return true, nil
+	}
+	if name == "testdata" || name == "vendor" {
+		return true, nil
+	}
+	// a nested module
+	if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+		return true, nil
+	}
+
+	rel := emitter.rel(path)
+	for _, pattern := range emitter.exclude {
+		if matchGlob(pattern, rel) {
+			return true, nil
+		}
+	}
+
+	if emitter.gitIgnore && emitter.ignored(path) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (emitter *Emitter) included(path string) bool {
+	if len(emitter.include) == 0 {
+		return true
+	}
+	elems := strings.Split(emitter.rel(path), "/")
+	for _, pattern := range emitter.include {
+		// a directory below a matching directory is included too
+		for i := len(elems); i > 0; i-- {
+			if matchGlob(pattern, strings.Join(elems[:i], "/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ignored reports whether the directory at path is ignored by the
+// .gitignore file of a directory above it, the closest file decides.
+func (emitter *Emitter) ignored(path string) bool {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if ignore, ok := emitter.ignores[dir]; ok {
+			rel, err := filepath.Rel(dir, path)
+			if err == nil {
+				if ignored, ok := ignore.match(filepath.ToSlash(rel), true); ok {
+					return ignored
+				}
+			}
+		}
+		if dir == emitter.root || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// rel is the slash separated path of path relative to the root, . for the
+// root.
+func (emitter *Emitter) rel(path string) string {
+	rel, err := filepath.Rel(emitter.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
 }
 
 func (emitter *Emitter) Close() {
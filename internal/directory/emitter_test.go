@@ -1,9 +1,11 @@
 package directory_test
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"testing"
 
 	"github.com/samlitowitz/goimportcycle/internal/directory"
@@ -30,17 +32,20 @@ func TestEmitter_WalkDirFunc_EmitsNothingAfterError(t *testing.T) {
 	// -- END -- //
 
 	emitter, output := directory.NewEmitter()
+	done := make(chan struct{})
 	go func(output <-chan string) {
+		defer close(done)
 		for range output {
-			t.Fatal("no directories should be emitted")
+			t.Error("no directories should be emitted")
 		}
 	}(output)
 
 	err = filepath.WalkDir(tmpDir+"/NONEXISTENT_DIR", emitter.WalkDirFunc)
+	emitter.Close()
+	<-done
 	if err == nil {
 		t.Fatal("failed to emit error")
 	}
-	emitter.Close()
 }
 
 func TestEmitter_WalkDirFunc_EmitsAppropriateDirectories(t *testing.T) {
@@ -88,10 +93,13 @@ func TestEmitter_WalkDirFunc_EmitsAppropriateDirectories(t *testing.T) {
 	expectedDirectories := makeTree(t, tree)
 
 	emitter, output := directory.NewEmitter()
+	done := make(chan struct{})
 	go func(output <-chan string, expectedFiles map[string]struct{}) {
+		defer close(done)
 		for actualPath := range output {
 			if _, ok := expectedFiles[actualPath]; !ok {
-				t.Fatalf("unexpected path: %s", actualPath)
+				t.Errorf("unexpected path: %s", actualPath)
+				continue
 			}
 			delete(expectedFiles, actualPath)
 		}
@@ -100,17 +108,143 @@ func TestEmitter_WalkDirFunc_EmitsAppropriateDirectories(t *testing.T) {
 			for expectedFile := range expectedFiles {
 				missedPaths += ", " + expectedFile
 			}
-			t.Fatalf(
+			t.Errorf(
 				"not all expected paths sent: missing %s",
 				missedPaths,
 			)
 		}
 	}(output, expectedDirectories)
 	err = filepath.WalkDir(tree.name, emitter.WalkDirFunc)
+	emitter.Close()
+	<-done
 	if err != nil {
 		t.Fatal(err)
 	}
-	emitter.Close()
+}
+
+func TestEmitter_Walk_SkipsDirectories(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			{"go.mod", nil},
+			{".git", []*Node{}},
+			{"_old", []*Node{}},
+			{"a", []*Node{{"testdata", []*Node{}}}},
+			{"vendor", []*Node{{"b", []*Node{}}}},
+			{"nested", []*Node{{"go.mod", nil}, {"c", []*Node{}}}},
+			{"gen", []*Node{{"d", []*Node{}}}},
+			{"ignored", []*Node{}},
+			{"kept", []*Node{{"ignored", []*Node{}}}},
+			{"loop", []*Node{}},
+		},
+	}
+	makeTree(t, tree)
+	err = os.WriteFile(
+		filepath.Join("testdata", ".gitignore"),
+		[]byte("# generated\n/ignored/\n"),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("..", filepath.Join("testdata", "loop", "parent"))
+	if err != nil {
+		t.Skip("symbolic links not supported:", err)
+	}
+	err = os.Symlink(filepath.Join("..", "a"), filepath.Join("testdata", "loop", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		include, exclude []string
+		gitIgnore        bool
+		expected         []string
+	}{
+		"go tool rules": {
+			expected: []string{".", "a", "gen", "gen/d", "ignored", "kept", "kept/ignored", "loop"},
+		},
+		"gitignore": {
+			gitIgnore: true,
+			expected:  []string{".", "a", "gen", "gen/d", "kept", "kept/ignored", "loop"},
+		},
+		"exclude": {
+			exclude:  []string{"gen", "**/ignored"},
+			expected: []string{".", "a", "kept", "loop"},
+		},
+		"include": {
+			include:  []string{"gen/*", "kept"},
+			expected: []string{"gen/d", "kept", "kept/ignored"},
+		},
+		"include tree": {
+			include:  []string{"gen"},
+			expected: []string{"gen", "gen/d"},
+		},
+		"include and exclude": {
+			include:  []string{"kept"},
+			exclude:  []string{"**/ignored"},
+			expected: []string{"kept"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		emitter, output := directory.NewEmitter()
+		emitter.SetGitIgnore(testCase.gitIgnore)
+		if err := emitter.SetInclude(testCase.include); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if err := emitter.SetExclude(testCase.exclude); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		errOut := make(chan error, 1)
+		go func() {
+			defer emitter.Close()
+			errOut <- emitter.Walk(context.Background(), "testdata")
+		}()
+
+		root := filepath.Join(tmpDir, "testdata")
+		var actual []string
+		for path := range output {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		if err := <-errOut; err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		slices.Sort(actual)
+		if !slices.Equal(testCase.expected, actual) {
+			t.Errorf("%s: expected %v, got %v", name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestEmitter_SetExclude_InvalidPattern(t *testing.T) {
+	emitter, _ := directory.NewEmitter()
+	if err := emitter.SetExclude([]string{"a/["}); err == nil {
+		t.Fatal("expected error")
+	}
 }
 
 // REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L449
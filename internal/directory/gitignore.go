@@ -0,0 +1,80 @@
+package directory
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile holds the rules of a .gitignore file, later rules take
+// precedence.
+type ignoreFile struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// readIgnoreFile reads the .gitignore file of dir, nil if there is none.
+func readIgnoreFile(dir string) (*ignoreFile, error) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ignore := &ignoreFile{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// a pattern without a slash matches at any depth
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if validateGlob(rule.pattern) != nil {
+			continue
+		}
+		ignore.rules = append(ignore.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ignore, nil
+}
+
+// match reports whether the slash separated name, relative to the directory
+// of the .gitignore file, is ignored, ok is false when no rule matches.
+func (ignore *ignoreFile) match(name string, isDir bool) (ignored, ok bool) {
+	for i := len(ignore.rules) - 1; i >= 0; i-- {
+		rule := ignore.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchGlob(rule.pattern, name) {
+			return !rule.negate, true
+		}
+	}
+	return false, false
+}
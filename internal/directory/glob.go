@@ -0,0 +1,55 @@
+package directory
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// validateGlob reports a malformed pattern.
+func validateGlob(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "**" {
+			continue
+		}
+		if _, err := path.Match(elem, ""); err != nil {
+			return fmt.Errorf("%s: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether the slash separated name matches pattern. Each
+// element of pattern is matched with path.Match against one element of name,
+// ** matches any number of elements.
+func matchGlob(pattern, name string) bool {
+	return matchElems(
+		strings.Split(strings.Trim(pattern, "/"), "/"),
+		strings.Split(name, "/"),
+	)
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
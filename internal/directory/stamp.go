@@ -0,0 +1,35 @@
+package directory
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Stamp identifies the files of the directory at path for which match
+// reports true by name, size, and modification time, so it changes whenever
+// one of them is added, removed, or written. It is empty if the files cannot
+// be listed.
+func Stamp(path string, match func(name string) bool) string {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return ""
+	}
+	var stamp strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || !match(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(&stamp, "%s %d %d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp.String()
+}
+
+// IsGoFile reports whether name is the name of a .go file.
+func IsGoFile(name string) bool {
+	return strings.HasSuffix(name, ".go")
+}
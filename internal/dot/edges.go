@@ -0,0 +1,116 @@
+package dot
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+// edge aggregates every import drawn between two nodes.
+type edge struct {
+	from, to string
+	// lhead is the cluster the edge points at, if any
+	lhead   string
+	inCycle bool
+	style   string
+	// provenance are the imports of the edge with a known position, listed
+	// in its tooltip once it is written so the tooltips of every edge are
+	// not held in memory at once
+	provenance []*graph.Provenance
+}
+
+// edgeSet collects edges, adding an edge between the same nodes again
+// aggregates it with the existing edge.
+type edgeSet struct {
+	edges []*edge
+	byKey map[[3]string]*edge
+}
+
+func newEdgeSet() *edgeSet {
+	return &edgeSet{
+		byKey: make(map[[3]string]*edge),
+	}
+}
+
+// add adds an edge, an aggregated edge is in a cycle when any of its imports
+// is and keeps a style only when every import shares it. The provenance of
+// the edge is added to its tooltip when its position is known.
+func (set *edgeSet) add(from, to, lhead string, inCycle bool, style string, prov *graph.Provenance) {
+	key := [3]string{from, to, lhead}
+	e, ok := set.byKey[key]
+	if !ok {
+		e = &edge{
+			from:  from,
+			to:    to,
+			lhead: lhead,
+			style: style,
+		}
+		set.byKey[key] = e
+		set.edges = append(set.edges, e)
+	}
+	e.inCycle = e.inCycle || inCycle
+	if e.style != style {
+		e.style = ""
+	}
+	if prov.Import.Pos.IsValid() {
+		e.provenance = append(e.provenance, prov)
+	}
+}
+
+// tooltip lists where the imports of the edge are and where they are used.
+func (e *edge) tooltip() []string {
+	var tooltip []string
+	for _, prov := range e.provenance {
+		desc := prov.String()
+		if !slices.Contains(tooltip, desc) {
+			tooltip = append(tooltip, desc)
+		}
+	}
+	return tooltip
+}
+
+// write writes the edges ordered by the nodes they connect.
+func (set *edgeSet) write(buf *bufio.Writer, cfg *config.Config) {
+	edgeDef := `
+	"%s" -> "%s" [color="%s"%s%s%s];`
+
+	slices.SortFunc(set.edges, func(a, b *edge) int {
+		if c := cmp.Compare(a.from, b.from); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.to, b.to); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.lhead, b.lhead)
+	})
+	for _, e := range set.edges {
+		arrowColor := cfg.Palette.Base.ImportArrow
+		if e.inCycle {
+			arrowColor = cfg.Palette.Cycle.ImportArrow
+		}
+		lhead := ""
+		if e.lhead != "" {
+			lhead = fmt.Sprintf(`, lhead="cluster_%s"`, e.lhead)
+		}
+		tooltip := ""
+		if lines := e.tooltip(); len(lines) > 0 {
+			tooltip = fmt.Sprintf(`, tooltip="%s"`, escape(strings.Join(lines, "\n")))
+		}
+		buf.WriteString(
+			fmt.Sprintf(
+				edgeDef,
+				e.from,
+				e.to,
+				arrowColor.Hex(),
+				lhead,
+				e.style,
+				tooltip,
+			),
+		)
+	}
+}
@@ -1,18 +1,19 @@
 package dot
 
 import (
-	"bytes"
+	"bufio"
 	"fmt"
 
 	"github.com/samlitowitz/goimportcycle/internal"
 	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
 )
 
-func writeNodeDefsForFileResolution(buf *bytes.Buffer, cfg *config.Config, pkgs []*internal.Package) {
+func writeNodeDefsForFileResolution(buf *bufio.Writer, cfg *config.Config, pkgs []*internal.Package) {
 	clusterDefHeader := `
 	subgraph "cluster_%s" {
 		label="%s";
-		style="filled";
+		style="%s";
 		fontcolor="%s";
 		fillcolor="%s";
 `
@@ -40,16 +41,14 @@ func writeNodeDefsForFileResolution(buf *bytes.Buffer, cfg *config.Config, pkgs
 			fmt.Sprintf(
 				clusterDefHeader,
 				pkgNodeName(pkg),
-				pkg.ModuleRelativePath(),
+				pkgLabel(pkg),
+				pkgStyle(pkg),
 				pkgText.Hex(),
 				pkgBackground.Hex(),
 			),
 		)
-		for _, file := range pkg.Files {
-			if file.IsStub {
-				continue
-			}
-			if len(file.Decls) == 0 {
+		for _, file := range sortedFiles(pkg) {
+			if !isFileDrawn(cfg, file) {
 				continue
 			}
 			fileText := cfg.Palette.Base.FileName
@@ -62,7 +61,7 @@ func writeNodeDefsForFileResolution(buf *bytes.Buffer, cfg *config.Config, pkgs
 				fmt.Sprintf(
 					nodeDef,
 					fileNodeName(file),
-					file.FileName,
+					escape(file.FileName),
 					fileText.Hex(),
 					fileBackground.Hex(),
 				),
@@ -72,40 +71,92 @@ func writeNodeDefsForFileResolution(buf *bytes.Buffer, cfg *config.Config, pkgs
 	}
 }
 
-func writeRelationshipsForFileResolution(buf *bytes.Buffer, cfg *config.Config, pkgs []*internal.Package) {
-	edgeDef := `
-	"%s" -> "%s" [color="%s"];`
-
-	for _, pkg := range pkgs {
-		if pkg.IsStub {
+func writeRelationshipsForFileResolution(buf *bufio.Writer, cfg *config.Config, pkgs []*internal.Package) {
+	edges := newEdgeSet()
+	for _, e := range graph.Files(pkgs).Edges() {
+		if e.From.Package.IsStub || e.From.File.IsStub {
+			continue
+		}
+		if e.To.Package.IsStub {
 			continue
 		}
-		for _, file := range pkg.Files {
-			if file.IsStub {
+		for _, prov := range e.Provenance {
+			_, inCycle := prov.Import.ReferencedFilesInCycle[e.To.File.UID()]
+			if cfg.Edges == config.ImportEdges && prov.Import.InImportCycle {
+				inCycle = true
+			}
+			edges.add(
+				fileNodeName(e.From.File),
+				fileNodeName(e.To.File),
+				"",
+				inCycle,
+				edgeStyle(prov.Import),
+				prov,
+			)
+		}
+	}
+	if cfg.Edges == config.ImportEdges {
+		// an import without referenced declarations points at the imported
+		// package
+		for _, e := range graph.Packages(pkgs, config.ImportEdges).Edges() {
+			if e.From.Package.IsStub {
 				continue
 			}
-			for _, imp := range file.Imports {
-				if imp.Package == nil {
+			for _, prov := range e.Provenance {
+				if prov.File.IsStub || !isImportOnly(cfg, prov.Import) {
 					continue
 				}
-				if imp.Package.IsStub {
+				to := firstDrawnFile(cfg, e.To.Package)
+				if to == nil {
 					continue
 				}
-				for _, refTyp := range imp.ReferencedTypes {
-					arrowColor := cfg.Palette.Base.ImportArrow
-					if _, ok := imp.ReferencedFilesInCycle[refTyp.File.UID()]; ok {
-						arrowColor = cfg.Palette.Cycle.ImportArrow
-					}
-					buf.WriteString(
-						fmt.Sprintf(
-							edgeDef,
-							fileNodeName(file),
-							fileNodeName(refTyp.File),
-							arrowColor.Hex(),
-						),
-					)
-				}
+				edges.add(
+					fileNodeName(prov.File),
+					fileNodeName(to),
+					pkgNodeName(e.To.Package),
+					prov.Import.InImportCycle,
+					edgeStyle(prov.Import),
+					prov,
+				)
 			}
 		}
 	}
+	edges.write(buf, cfg)
+}
+
+// isImportOnly reports whether imp is an edge without referenced
+// declarations, these are only drawn with config.ImportEdges.
+func isImportOnly(cfg *config.Config, imp *internal.Import) bool {
+	return cfg.Edges == config.ImportEdges &&
+		len(imp.ReferencedTypes) == 0 &&
+		imp.Package != nil &&
+		!imp.Package.IsStub
+}
+
+func isFileDrawn(cfg *config.Config, file *internal.File) bool {
+	if file.IsStub {
+		return false
+	}
+	if len(file.Decls) > 0 {
+		return true
+	}
+	for _, imp := range file.Imports {
+		if isImportOnly(cfg, imp) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstDrawnFile(cfg *config.Config, pkg *internal.Package) *internal.File {
+	var first *internal.File
+	for _, file := range pkg.Files {
+		if !isFileDrawn(cfg, file) {
+			continue
+		}
+		if first == nil || file.FileName < first.FileName {
+			first = file
+		}
+	}
+	return first
 }
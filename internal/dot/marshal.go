@@ -1,9 +1,11 @@
 package dot
 
 import (
+	"bufio"
 	"bytes"
 	"cmp"
 	"fmt"
+	"io"
 	"slices"
 	"strings"
 
@@ -12,14 +14,29 @@
 	"github.com/samlitowitz/goimportcycle/internal"
 )
 
+// Marshal returns the DOT output of pkgs, see Write.
 func Marshal(cfg *config.Config, modulePath string, pkgs []*internal.Package) ([]byte, error) {
+	out := &bytes.Buffer{}
+	if err := Write(out, cfg, modulePath, pkgs); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Write writes the DOT output of pkgs to w as it is generated, so the output
+// is never held in memory as a whole.
+func Write(w io.Writer, cfg *config.Config, modulePath string, pkgs []*internal.Package) error {
 	slices.SortFunc(pkgs, pkgCmpFn)
 
-	buf := &bytes.Buffer{}
+	buf := bufio.NewWriter(w)
 
 	writeHeader(buf, modulePath)
 	switch cfg.Resolution {
 	case config.FileResolution:
+		if cfg.Edges == config.ImportEdges {
+			// import edges point at package clusters
+			buf.WriteString("\tcompound=\"true\";\n")
+		}
 		writeNodeDefsForFileResolution(buf, cfg, pkgs)
 		writeRelationshipsForFileResolution(buf, cfg, pkgs)
 	case config.PackageResolution:
@@ -28,14 +45,25 @@ func Marshal(cfg *config.Config, modulePath string, pkgs []*internal.Package) ([
 	}
 	writeFooter(buf)
 
-	return buf.Bytes(), nil
+	return buf.Flush()
 }
 
 func pkgCmpFn(a, b *internal.Package) int {
-	return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	return cmp.Compare(a.DirImportPath(), b.DirImportPath())
+}
+
+func sortedFiles(pkg *internal.Package) []*internal.File {
+	files := make([]*internal.File, 0, len(pkg.Files))
+	for _, file := range pkg.Files {
+		files = append(files, file)
+	}
+	slices.SortFunc(files, func(a, b *internal.File) int {
+		return cmp.Compare(a.AbsPath, b.AbsPath)
+	})
+	return files
 }
 
-func writeHeader(buf *bytes.Buffer, modulePath string) {
+func writeHeader(buf *bufio.Writer, modulePath string) {
 	buf.WriteString(
 		fmt.Sprintf(
 			`digraph {
@@ -44,35 +72,60 @@ func writeHeader(buf *bytes.Buffer, modulePath string) {
 	rankdir="TB";
 	node [shape="rect"];
 `,
-			modulePath,
+			escape(modulePath),
 		),
 	)
 }
 
-func writeFooter(buf *bytes.Buffer) {
+func writeFooter(buf *bufio.Writer) {
 	buf.WriteString(`
 }
 `,
 	)
 }
 
+func edgeStyle(imp *internal.Import) string {
+	if imp.IsLinkname {
+		return `, style="dotted"`
+	}
+	if imp.IsBlankImport() {
+		return `, style="dashed"`
+	}
+	return ""
+}
+
+// pkgLabel labels a package by its module relative path, marking packages
+// outside the analyzed package patterns.
+func pkgLabel(pkg *internal.Package) string {
+	if pkg.OutOfScope {
+		return escape(pkg.ModuleRelativePath() + " (out of scope)")
+	}
+	return escape(pkg.ModuleRelativePath())
+}
+
+func pkgStyle(pkg *internal.Package) string {
+	if pkg.OutOfScope {
+		return "filled,dashed"
+	}
+	return "filled"
+}
+
+// pkgNodeName identifies a package by the import path of its directory, so
+// packages sharing a name, e.g. two util or main packages, are distinct.
 func pkgNodeName(pkg *internal.Package) string {
-	return fmt.Sprintf(
-		"pkg_%s",
-		pkg.Name,
-	)
+	return escape("pkg:" + pkg.DirImportPath())
 }
 
 func fileNodeName(file *internal.File) string {
 	if file.Package == nil {
-		return fmt.Sprintf(
-			"file_%s",
-			file.FileName,
-		)
+		return escape("file:" + file.FileName)
 	}
-	return fmt.Sprintf(
-		"pkg_%s_file_%s",
-		file.Package.Name,
-		strings.TrimSuffix(file.FileName, ".go"),
-	)
+	return escape("file:" + file.Package.DirImportPath() + "/" + file.FileName)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes s for use in a double quoted DOT string.
+func escape(s string) string {
+	return escaper.Replace(s)
 }
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"errors"
 	"go/ast"
 	"go/parser"
 	"go/token"
@@ -10,6 +11,7 @@
 	"runtime"
 	"testing"
 
+	"github.com/samlitowitz/goimportcycle/internal"
 	"github.com/samlitowitz/goimportcycle/internal/config"
 	"github.com/samlitowitz/goimportcycle/internal/dot"
 
@@ -156,8 +158,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -226,36 +229,36 @@ func(path string, n *Node) {
 	rankdir="TB";
 	node [shape="rect"];
 
-	subgraph "cluster_pkg_a_a" {
-		label="a-a";
+	subgraph "cluster_pkg:example.com/simple" {
+		label="main";
 		style="filled";
-		fontcolor="#ff0000";
+		fontcolor="#000000";
 		fillcolor="#ffffff";
 
-		"pkg_a_a_file_a" [label="a.go", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
+		"file:example.com/simple/main.go" [label="main.go", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
 	};
 
-	subgraph "cluster_pkg_b_b" {
-		label="b.b";
+	subgraph "cluster_pkg:example.com/simple/a-a" {
+		label="a-a";
 		style="filled";
 		fontcolor="#ff0000";
 		fillcolor="#ffffff";
 
-		"pkg_b_b_file_b" [label="b.go", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
+		"file:example.com/simple/a-a/a.go" [label="a.go", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
 	};
 
-	subgraph "cluster_pkg_main" {
-		label="main";
+	subgraph "cluster_pkg:example.com/simple/b.b" {
+		label="b.b";
 		style="filled";
-		fontcolor="#000000";
+		fontcolor="#ff0000";
 		fillcolor="#ffffff";
 
-		"pkg_main_file_main" [label="main.go", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+		"file:example.com/simple/b.b/b.go" [label="b.go", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
 	};
 
-	"pkg_a_a_file_a" -> "pkg_b_b_file_b" [color="#ff0000"];
-	"pkg_b_b_file_b" -> "pkg_a_a_file_a" [color="#ff0000"];
-	"pkg_main_file_main" -> "pkg_a_a_file_a" [color="#000000"];
+	"file:example.com/simple/a-a/a.go" -> "file:example.com/simple/b.b/b.go" [color="#ff0000"];
+	"file:example.com/simple/b.b/b.go" -> "file:example.com/simple/a-a/a.go" [color="#ff0000"];
+	"file:example.com/simple/main.go" -> "file:example.com/simple/a-a/a.go" [color="#000000"];
 }
 `
 
@@ -409,8 +412,9 @@ func(path string, n *Node) {
 					fset := token.NewFileSet()
 					pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
 					if err != nil {
+						t.Errorf("%s: %s", testCase, err)
 						cancel()
-						t.Fatalf("%s: %s", testCase, err)
+						return
 					}
 
 					for _, pkg := range pkgs {
@@ -479,12 +483,12 @@ func(path string, n *Node) {
 	rankdir="TB";
 	node [shape="rect"];
 
-	"pkg_a_a" [label="a-a", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
-	"pkg_b_b" [label="b.b", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
-	"pkg_main" [label="main", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
-	"pkg_a_a" -> "pkg_b_b" [color="#ff0000"];
-	"pkg_b_b" -> "pkg_a_a" [color="#ff0000"];
-	"pkg_main" -> "pkg_a_a" [color="#000000"];
+	"pkg:example.com/simple" [label="main", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/simple/a-a" [label="a-a", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
+	"pkg:example.com/simple/b.b" [label="b.b", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
+	"pkg:example.com/simple" -> "pkg:example.com/simple/a-a" [color="#000000"];
+	"pkg:example.com/simple/a-a" -> "pkg:example.com/simple/b.b" [color="#ff0000"];
+	"pkg:example.com/simple/b.b" -> "pkg:example.com/simple/a-a" [color="#ff0000"];
 }
 `
 
@@ -562,3 +566,305 @@ func chtmpdir(t *testing.T) (restore func()) {
 		os.RemoveAll(d)
 	}
 }
+
+func TestMarshal_ImportEdges_BlankImport(t *testing.T) {
+	pkgA := &internal.Package{
+		DirName:       "/m/a",
+		ModulePath:    "example.com/m",
+		ModuleRoot:    "/m",
+		Name:          "a",
+		Files:         make(map[string]*internal.File),
+		InImportCycle: true,
+	}
+	pkgC := &internal.Package{
+		DirName:       "/m/c",
+		ModulePath:    "example.com/m",
+		ModuleRoot:    "/m",
+		Name:          "c",
+		Files:         make(map[string]*internal.File),
+		InImportCycle: true,
+	}
+	fileA := &internal.File{
+		Package:  pkgA,
+		FileName: "a.go",
+		AbsPath:  "/m/a/a.go",
+		Imports:  make(map[string]*internal.Import),
+		Decls:    make(map[string]*internal.Decl),
+	}
+	fileC := &internal.File{
+		Package:  pkgC,
+		FileName: "c.go",
+		AbsPath:  "/m/c/c.go",
+		Imports:  make(map[string]*internal.Import),
+		Decls:    make(map[string]*internal.Decl),
+	}
+	pkgA.Files[fileA.UID()] = fileA
+	pkgC.Files[fileC.UID()] = fileC
+	fileA.Decls["AFn"] = &internal.Decl{File: fileA, Name: "AFn"}
+	fileC.Decls["CFn"] = &internal.Decl{File: fileC, Name: "CFn"}
+	fileA.Imports["c"] = &internal.Import{
+		Package:         pkgC,
+		Name:            "c",
+		Path:            "example.com/m/c",
+		ReferencedTypes: map[string]*internal.Decl{"CFn": fileC.Decls["CFn"]},
+		InImportCycle:   true,
+	}
+	blank := &internal.Import{
+		Package:         pkgA,
+		Name:            "_",
+		Path:            "example.com/m/a",
+		ReferencedTypes: make(map[string]*internal.Decl),
+		InImportCycle:   true,
+	}
+	fileC.Imports[blank.UID()] = blank
+
+	expectedByResolution := map[config.Resolution]string{
+		config.FileResolution: `digraph {
+	labelloc="t";
+	label="example.com/m";
+	rankdir="TB";
+	node [shape="rect"];
+	compound="true";
+
+	subgraph "cluster_pkg:example.com/m/a" {
+		label="a";
+		style="filled";
+		fontcolor="#ff0000";
+		fillcolor="#ffffff";
+
+		"file:example.com/m/a/a.go" [label="a.go", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	};
+
+	subgraph "cluster_pkg:example.com/m/c" {
+		label="c";
+		style="filled";
+		fontcolor="#ff0000";
+		fillcolor="#ffffff";
+
+		"file:example.com/m/c/c.go" [label="c.go", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	};
+
+	"file:example.com/m/a/a.go" -> "file:example.com/m/c/c.go" [color="#ff0000"];
+	"file:example.com/m/c/c.go" -> "file:example.com/m/a/a.go" [color="#ff0000", lhead="cluster_pkg:example.com/m/a", style="dashed"];
+}
+`,
+		config.PackageResolution: `digraph {
+	labelloc="t";
+	label="example.com/m";
+	rankdir="TB";
+	node [shape="rect"];
+
+	"pkg:example.com/m/a" [label="a", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
+	"pkg:example.com/m/c" [label="c", style="filled", fontcolor="#ff0000", fillcolor="#ffffff"];
+	"pkg:example.com/m/a" -> "pkg:example.com/m/c" [color="#ff0000"];
+	"pkg:example.com/m/c" -> "pkg:example.com/m/a" [color="#ff0000", style="dashed"];
+}
+`,
+	}
+
+	for resolution, expected := range expectedByResolution {
+		cfg := config.Default()
+		cfg.Resolution = resolution
+		cfg.Edges = config.ImportEdges
+		actual, err := dot.Marshal(cfg, "example.com/m", []*internal.Package{pkgA, pkgC})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(expected, string(actual)) {
+			t.Error(cmp.Diff(expected, string(actual)))
+		}
+	}
+}
+
+func TestMarshal_PackagesSharingNames(t *testing.T) {
+	newPkg := func(dir, name string) *internal.Package {
+		return &internal.Package{
+			DirName:    "/m/" + dir,
+			ModulePath: "example.com/m",
+			ModuleRoot: "/m",
+			Name:       name,
+			Files:      make(map[string]*internal.File),
+		}
+	}
+	addFile := func(pkg *internal.Package, name string, imported *internal.Package) {
+		file := &internal.File{
+			Package:  pkg,
+			FileName: name,
+			AbsPath:  pkg.DirName + "/" + name,
+			Imports:  make(map[string]*internal.Import),
+			Decls:    map[string]*internal.Decl{"Fn": {Name: "Fn"}},
+		}
+		file.Decls["Fn"].File = file
+		if imported != nil {
+			file.Imports[imported.Name] = &internal.Import{
+				Package:         imported,
+				Name:            imported.Name,
+				Path:            imported.ImportPath(),
+				ReferencedTypes: make(map[string]*internal.Decl),
+			}
+		}
+		pkg.Files[file.UID()] = file
+	}
+	utilA := newPkg("a/util", "util")
+	utilB := newPkg("b/util", "util")
+	api := newPkg("cmd/api", "main")
+	worker := newPkg("cmd/worker", "main")
+	weird := newPkg(`we"ird`, "weird")
+	addFile(utilA, "util.go", nil)
+	addFile(utilB, "util.go", nil)
+	addFile(api, "main.go", utilA)
+	addFile(worker, "main.go", utilB)
+	addFile(weird, "weird.go", nil)
+
+	expected := `digraph {
+	labelloc="t";
+	label="example.com/m";
+	rankdir="TB";
+	node [shape="rect"];
+
+	"pkg:example.com/m/a/util" [label="a/util", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/m/b/util" [label="b/util", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/m/cmd/api" [label="cmd/api:main", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/m/cmd/worker" [label="cmd/worker:main", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/m/we\"ird" [label="we\"ird", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/m/cmd/api" -> "pkg:example.com/m/a/util" [color="#000000"];
+	"pkg:example.com/m/cmd/worker" -> "pkg:example.com/m/b/util" [color="#000000"];
+}
+`
+
+	cfg := config.Default()
+	cfg.Resolution = config.PackageResolution
+	actual, err := dot.Marshal(cfg, "example.com/m", []*internal.Package{weird, utilB, worker, utilA, api})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(expected, string(actual)) {
+		t.Error(cmp.Diff(expected, string(actual)))
+	}
+}
+
+func TestMarshal_AggregatesDuplicateEdges(t *testing.T) {
+	pkgA := &internal.Package{
+		DirName:    "/m/a",
+		ModulePath: "example.com/m",
+		ModuleRoot: "/m",
+		Name:       "a",
+		Files:      make(map[string]*internal.File),
+	}
+	pkgC := &internal.Package{
+		DirName:    "/m/c",
+		ModulePath: "example.com/m",
+		ModuleRoot: "/m",
+		Name:       "c",
+		Files:      make(map[string]*internal.File),
+	}
+	fileC := &internal.File{
+		Package:  pkgC,
+		FileName: "c.go",
+		AbsPath:  "/m/c/c.go",
+		Imports:  make(map[string]*internal.Import),
+		Decls:    make(map[string]*internal.Decl),
+	}
+	pkgC.Files[fileC.UID()] = fileC
+	for _, name := range []string{"CFn", "CType", "CConst"} {
+		fileC.Decls[name] = &internal.Decl{File: fileC, Name: name}
+	}
+	for _, name := range []string{"b.go", "a.go"} {
+		file := &internal.File{
+			Package:  pkgA,
+			FileName: name,
+			AbsPath:  "/m/a/" + name,
+			Imports:  make(map[string]*internal.Import),
+			Decls:    map[string]*internal.Decl{name: {Name: name}},
+		}
+		file.Imports["c"] = &internal.Import{
+			Package:         pkgC,
+			Name:            "c",
+			Path:            "example.com/m/c",
+			ReferencedTypes: fileC.Decls,
+		}
+		pkgA.Files[file.UID()] = file
+	}
+
+	expectedByResolution := map[config.Resolution]string{
+		config.FileResolution: `digraph {
+	labelloc="t";
+	label="example.com/m";
+	rankdir="TB";
+	node [shape="rect"];
+
+	subgraph "cluster_pkg:example.com/m/a" {
+		label="a";
+		style="filled";
+		fontcolor="#000000";
+		fillcolor="#ffffff";
+
+		"file:example.com/m/a/a.go" [label="a.go", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+		"file:example.com/m/a/b.go" [label="b.go", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	};
+
+	subgraph "cluster_pkg:example.com/m/c" {
+		label="c";
+		style="filled";
+		fontcolor="#000000";
+		fillcolor="#ffffff";
+
+		"file:example.com/m/c/c.go" [label="c.go", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	};
+
+	"file:example.com/m/a/a.go" -> "file:example.com/m/c/c.go" [color="#000000"];
+	"file:example.com/m/a/b.go" -> "file:example.com/m/c/c.go" [color="#000000"];
+}
+`,
+		config.PackageResolution: `digraph {
+	labelloc="t";
+	label="example.com/m";
+	rankdir="TB";
+	node [shape="rect"];
+
+	"pkg:example.com/m/a" [label="a", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/m/c" [label="c", style="filled", fontcolor="#000000", fillcolor="#ffffff"];
+	"pkg:example.com/m/a" -> "pkg:example.com/m/c" [color="#000000"];
+}
+`,
+	}
+
+	for resolution, expected := range expectedByResolution {
+		cfg := config.Default()
+		cfg.Resolution = resolution
+		// map iteration order must not change the output
+		for i := 0; i < 10; i++ {
+			actual, err := dot.Marshal(cfg, "example.com/m", []*internal.Package{pkgC, pkgA})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(expected, string(actual)) {
+				t.Fatal(cmp.Diff(expected, string(actual)))
+			}
+		}
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestWrite_WriterError(t *testing.T) {
+	pkg := &internal.Package{
+		DirName:    "/m/a",
+		ModulePath: "example.com/m",
+		ModuleRoot: "/m",
+		Name:       "a",
+		Files:      make(map[string]*internal.File),
+	}
+	expected := errors.New("disk full")
+	cfg := config.Default()
+	err := dot.Write(failingWriter{err: expected}, cfg, "example.com/m", []*internal.Package{pkg})
+	if !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
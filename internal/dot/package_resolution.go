@@ -1,16 +1,17 @@
 package dot
 
 import (
-	"bytes"
+	"bufio"
 	"fmt"
 
 	"github.com/samlitowitz/goimportcycle/internal"
 	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
 )
 
-func writeNodeDefsForPackageResolution(buf *bytes.Buffer, cfg *config.Config, pkgs []*internal.Package) {
+func writeNodeDefsForPackageResolution(buf *bufio.Writer, cfg *config.Config, pkgs []*internal.Package) {
 	nodeDef := `
-	"%s" [label="%s", style="filled", fontcolor="%s", fillcolor="%s"];`
+	"%s" [label="%s", style="%s", fontcolor="%s", fillcolor="%s"];`
 
 	for _, pkg := range pkgs {
 		if pkg.IsStub {
@@ -30,7 +31,8 @@ func writeNodeDefsForPackageResolution(buf *bytes.Buffer, cfg *config.Config, pk
 			fmt.Sprintf(
 				nodeDef,
 				pkgNodeName(pkg),
-				pkg.ModuleRelativePath(),
+				pkgLabel(pkg),
+				pkgStyle(pkg),
 				pkgText.Hex(),
 				pkgBackground.Hex(),
 			),
@@ -38,38 +40,26 @@ func writeNodeDefsForPackageResolution(buf *bytes.Buffer, cfg *config.Config, pk
 	}
 }
 
-func writeRelationshipsForPackageResolution(buf *bytes.Buffer, cfg *config.Config, pkgs []*internal.Package) {
-	edgeDef := `
-	"%s" -> "%s" [color="%s"];`
-
-	for _, pkg := range pkgs {
-		if pkg.IsStub {
+func writeRelationshipsForPackageResolution(buf *bufio.Writer, cfg *config.Config, pkgs []*internal.Package) {
+	edges := newEdgeSet()
+	// every import is drawn, edges in a cycle are marked up by the builder
+	for _, e := range graph.Packages(pkgs, config.ImportEdges).Edges() {
+		if e.From.Package.IsStub || e.To.Package.IsStub {
 			continue
 		}
-		for _, file := range pkg.Files {
-			if file.IsStub {
+		for _, prov := range e.Provenance {
+			if prov.File.IsStub {
 				continue
 			}
-			for _, imp := range file.Imports {
-				if imp.Package == nil {
-					continue
-				}
-				if imp.Package.IsStub {
-					continue
-				}
-				arrowColor := cfg.Palette.Base.ImportArrow
-				if imp.InImportCycle {
-					arrowColor = cfg.Palette.Cycle.ImportArrow
-				}
-				buf.WriteString(
-					fmt.Sprintf(
-						edgeDef,
-						pkgNodeName(pkg),
-						pkgNodeName(imp.Package),
-						arrowColor.Hex(),
-					),
-				)
-			}
+			edges.add(
+				pkgNodeName(e.From.Package),
+				pkgNodeName(e.To.Package),
+				"",
+				prov.Import.InImportCycle,
+				edgeStyle(prov.Import),
+				prov,
+			)
 		}
 	}
+	edges.write(buf, cfg)
 }
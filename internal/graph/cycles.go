@@ -0,0 +1,131 @@
+package graph
+
+import "slices"
+
+// ElementaryCycles returns the elementary cycles of the graph made of the
+// nodes 0 through n-1 using Johnson's algorithm. Each cycle starts at its
+// lowest numbered node and does not repeat it at the end. Enumeration stops
+// once limit cycles have been found, a limit of zero or less finds every
+// cycle. The second return value reports whether the limit was reached.
+func ElementaryCycles(n int, successors func(node int) []int, limit int) ([][]int, bool) {
+	adjacent := make([][]int, n)
+	for node := range adjacent {
+		adjacent[node] = successors(node)
+	}
+
+	cycles := make([][]int, 0)
+	limitReached := false
+
+	blocked := make([]bool, n)
+	blockedBy := make([]map[int]struct{}, n)
+	inComponent := make([]bool, n)
+	stack := make([]int, 0)
+
+	var unblock func(node int)
+	unblock = func(node int) {
+		blocked[node] = false
+		for other := range blockedBy[node] {
+			delete(blockedBy[node], other)
+			if blocked[other] {
+				unblock(other)
+			}
+		}
+	}
+
+	var circuit func(node, start int) bool
+	circuit = func(node, start int) bool {
+		found := false
+		stack = append(stack, node)
+		blocked[node] = true
+
+		for _, succ := range adjacent[node] {
+			if limitReached {
+				break
+			}
+			if !inComponent[succ] {
+				continue
+			}
+			if succ == start {
+				cycles = append(cycles, slices.Clone(stack))
+				found = true
+				if limit > 0 && len(cycles) >= limit {
+					limitReached = true
+				}
+				continue
+			}
+			if !blocked[succ] && circuit(succ, start) {
+				found = true
+			}
+		}
+
+		if found {
+			unblock(node)
+		} else {
+			for _, succ := range adjacent[node] {
+				if !inComponent[succ] {
+					continue
+				}
+				blockedBy[succ][node] = struct{}{}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		return found
+	}
+
+	for start := 0; start < n && !limitReached; {
+		component := leastCyclicComponent(n, start, adjacent)
+		if component == nil {
+			break
+		}
+		least := slices.Min(component)
+
+		for i := range inComponent {
+			inComponent[i] = false
+		}
+		for _, node := range component {
+			inComponent[node] = true
+			blocked[node] = false
+			blockedBy[node] = make(map[int]struct{})
+		}
+
+		circuit(least, least)
+		start = least + 1
+	}
+
+	return cycles, limitReached
+}
+
+// leastCyclicComponent returns the strongly connected component, of the
+// subgraph induced by the nodes start through n-1, which contains a cycle and
+// the lowest numbered node. It returns nil if there is no such component.
+func leastCyclicComponent(n, start int, adjacent [][]int) []int {
+	successors := func(node int) []int {
+		if node < start {
+			return nil
+		}
+		succs := make([]int, 0, len(adjacent[node]))
+		for _, succ := range adjacent[node] {
+			if succ < start {
+				continue
+			}
+			succs = append(succs, succ)
+		}
+		return succs
+	}
+
+	var least []int
+	leastNode := n
+	for _, component := range StronglyConnectedComponents(n, successors) {
+		if len(component) == 1 && !slices.Contains(successors(component[0]), component[0]) {
+			continue
+		}
+		minNode := slices.Min(component)
+		if minNode >= leastNode {
+			continue
+		}
+		least = component
+		leastNode = minNode
+	}
+	return least
+}
@@ -0,0 +1,104 @@
+package graph_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+func TestElementaryCycles(t *testing.T) {
+	testCases := map[string]struct {
+		n     int
+		edges map[int][]int
+		limit int
+
+		expected     [][]int
+		limitReached bool
+	}{
+		"none": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {2},
+			},
+			expected: [][]int{},
+		},
+		"simple": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {0},
+				2: {0},
+			},
+			expected: [][]int{{0, 1}},
+		},
+		"transitive": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {2},
+				2: {0},
+			},
+			expected: [][]int{{0, 1, 2}},
+		},
+		"interlinked": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {0, 2},
+				2: {1},
+			},
+			expected: [][]int{{0, 1}, {1, 2}},
+		},
+		"complete": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1, 2},
+				1: {0, 2},
+				2: {0, 1},
+			},
+			expected: [][]int{{0, 1}, {0, 1, 2}, {0, 2}, {0, 2, 1}, {1, 2}},
+		},
+		"self loop": {
+			n: 2,
+			edges: map[int][]int{
+				0: {1},
+				1: {1},
+			},
+			expected: [][]int{{1}},
+		},
+		"limited": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1, 2},
+				1: {0, 2},
+				2: {0, 1},
+			},
+			limit:        2,
+			expected:     [][]int{{0, 1}, {0, 1, 2}},
+			limitReached: true,
+		},
+	}
+
+	for testCase, tc := range testCases {
+		actual, limitReached := graph.ElementaryCycles(
+			tc.n,
+			func(node int) []int {
+				return tc.edges[node]
+			},
+			tc.limit,
+		)
+		if limitReached != tc.limitReached {
+			t.Errorf("%s: expected limit reached to be %t", testCase, tc.limitReached)
+		}
+		if len(actual) != len(tc.expected) {
+			t.Fatalf("%s: expected %d cycles, got %d: %v", testCase, len(tc.expected), len(actual), actual)
+		}
+		for i := range tc.expected {
+			if !slices.Equal(tc.expected[i], actual[i]) {
+				t.Errorf("%s: cycle %d: expected %v, got %v", testCase, i, tc.expected[i], actual[i])
+			}
+		}
+	}
+}
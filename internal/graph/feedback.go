@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Arc is an edge of the graph made of the nodes 0 through n-1, see
+// ElementaryCycles.
+type Arc struct {
+	From, To int
+}
+
+// FeedbackArcSet returns arcs, in the order they were chosen, such that
+// every cycle of cycles, as returned by ElementaryCycles, has one of them.
+// Removing the arcs leaves the graph without cycles when cycles are all of
+// its elementary cycles. Arcs are chosen greedily, the arc on the most cycles
+// not broken yet per unit of weight first, and the chosen arcs whose cycles
+// are all broken by the others are dropped again, so the set is minimal but
+// not necessarily minimum. Weights are at least 1.
+func FeedbackArcSet(cycles [][]int, weight func(arc Arc) int) []Arc {
+	arcWeight := func(arc Arc) int {
+		if w := weight(arc); w > 1 {
+			return w
+		}
+		return 1
+	}
+
+	onCycles := make(map[Arc][]int)
+	for i, c := range cycles {
+		for k, from := range c {
+			arc := Arc{From: from, To: c[(k+1)%len(c)]}
+			onCycles[arc] = append(onCycles[arc], i)
+		}
+	}
+	arcs := make([]Arc, 0, len(onCycles))
+	for arc := range onCycles {
+		arcs = append(arcs, arc)
+	}
+	slices.SortFunc(arcs, compareArcs)
+
+	// broken counts the chosen arcs of each cycle
+	broken := make([]int, len(cycles))
+	unbroken := len(cycles)
+	var chosen []Arc
+	for unbroken > 0 {
+		var best Arc
+		bestCount, bestWeight := 0, 0
+		for _, arc := range arcs {
+			count := 0
+			for _, i := range onCycles[arc] {
+				if broken[i] == 0 {
+					count++
+				}
+			}
+			if count == 0 {
+				continue
+			}
+			w := arcWeight(arc)
+			// count/w > bestCount/bestWeight, the lighter arc on a tie
+			better := count*bestWeight - bestCount*w
+			if bestCount == 0 || better > 0 || (better == 0 && w < bestWeight) {
+				best, bestCount, bestWeight = arc, count, w
+			}
+		}
+		for _, i := range onCycles[best] {
+			broken[i]++
+		}
+		unbroken -= bestCount
+		chosen = append(chosen, best)
+	}
+
+	// the arcs chosen last were needed least when they were chosen
+	for i := len(chosen) - 1; i >= 0; i-- {
+		needed := false
+		for _, c := range onCycles[chosen[i]] {
+			if broken[c] == 1 {
+				needed = true
+				break
+			}
+		}
+		if needed {
+			continue
+		}
+		for _, c := range onCycles[chosen[i]] {
+			broken[c]--
+		}
+		chosen = slices.Delete(chosen, i, i+1)
+	}
+	return chosen
+}
+
+func compareArcs(a, b Arc) int {
+	if c := cmp.Compare(a.From, b.From); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.To, b.To)
+}
@@ -0,0 +1,155 @@
+package graph_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+func TestFeedbackArcSet(t *testing.T) {
+	testCases := map[string]struct {
+		n       int
+		edges   map[int][]int
+		weights map[graph.Arc]int
+		limit   int
+
+		expected []graph.Arc
+	}{
+		"none": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {2},
+			},
+			expected: []graph.Arc{},
+		},
+		"simple": {
+			n: 2,
+			edges: map[int][]int{
+				0: {1},
+				1: {0},
+			},
+			expected: []graph.Arc{{From: 0, To: 1}},
+		},
+		"lightest": {
+			n: 2,
+			edges: map[int][]int{
+				0: {1},
+				1: {0},
+			},
+			weights: map[graph.Arc]int{
+				{From: 0, To: 1}: 3,
+			},
+			expected: []graph.Arc{{From: 1, To: 0}},
+		},
+		"shared": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {0, 2},
+				2: {0, 1},
+			},
+			expected: []graph.Arc{{From: 0, To: 1}, {From: 1, To: 2}},
+		},
+		"most cycles per weight": {
+			n: 4,
+			edges: map[int][]int{
+				0: {1, 2, 3},
+				1: {0},
+				2: {0},
+				3: {0},
+			},
+			weights: map[graph.Arc]int{
+				{From: 1, To: 0}: 2,
+				{From: 2, To: 0}: 2,
+				{From: 3, To: 0}: 2,
+			},
+			expected: []graph.Arc{{From: 0, To: 1}, {From: 0, To: 2}, {From: 0, To: 3}},
+		},
+		"hub": {
+			n: 4,
+			edges: map[int][]int{
+				0: {1, 2, 3},
+				1: {0},
+				2: {0},
+				3: {0},
+			},
+			weights: map[graph.Arc]int{
+				{From: 0, To: 1}: 2,
+				{From: 0, To: 2}: 2,
+				{From: 0, To: 3}: 2,
+			},
+			expected: []graph.Arc{{From: 1, To: 0}, {From: 2, To: 0}, {From: 3, To: 0}},
+		},
+		"self loop": {
+			n: 2,
+			edges: map[int][]int{
+				0: {1},
+				1: {1},
+			},
+			expected: []graph.Arc{{From: 1, To: 1}},
+		},
+		"complete": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1, 2},
+				1: {0, 2},
+				2: {0, 1},
+			},
+			expected: []graph.Arc{{From: 0, To: 1}, {From: 0, To: 2}, {From: 1, To: 2}},
+		},
+		"limited": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1, 2},
+				1: {0, 2},
+				2: {0, 1},
+			},
+			limit:    2,
+			expected: []graph.Arc{{From: 0, To: 1}},
+		},
+	}
+
+	for testCase, tc := range testCases {
+		successors := func(node int) []int {
+			return tc.edges[node]
+		}
+		cycles, limitReached := graph.ElementaryCycles(tc.n, successors, tc.limit)
+		actual := graph.FeedbackArcSet(cycles, func(arc graph.Arc) int {
+			return tc.weights[arc]
+		})
+		if !slices.Equal(tc.expected, actual) {
+			t.Errorf("%s: expected %v, got %v", testCase, tc.expected, actual)
+		}
+		if limitReached {
+			continue
+		}
+
+		removed := func(node int) []int {
+			return slices.DeleteFunc(slices.Clone(successors(node)), func(succ int) bool {
+				return slices.Contains(actual, graph.Arc{From: node, To: succ})
+			})
+		}
+		if left, _ := graph.ElementaryCycles(tc.n, removed, 0); len(left) != 0 {
+			t.Errorf("%s: expected no cycles left, got %v", testCase, left)
+		}
+	}
+}
+
+func TestFeedbackArcSet_Redundant(t *testing.T) {
+	// 0 -> 1 is on the most cycles, but 2 -> 3 and 4 -> 5, chosen for the
+	// last two cycles, break every cycle 0 -> 1 is on
+	cycles := [][]int{
+		{0, 1, 2, 3},
+		{0, 1, 6, 2, 3, 7},
+		{0, 1, 4, 5},
+		{2, 3, 8},
+		{4, 5, 9},
+	}
+	actual := graph.FeedbackArcSet(cycles, func(graph.Arc) int { return 1 })
+	expected := []graph.Arc{{From: 2, To: 3}, {From: 4, To: 5}}
+	if !slices.Equal(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
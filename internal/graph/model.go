@@ -0,0 +1,572 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+)
+
+// Level is the granularity of the nodes of a Graph.
+type Level int
+
+const (
+	PackageLevel Level = iota
+	FileLevel
+	// DeclLevel graphs are made of files and declarations, a file has an
+	// edge to every declaration it references.
+	DeclLevel
+)
+
+// Node is a package, file, or declaration of a Graph. Package is set at every
+// level, File is set for files and declarations, and Decl for declarations.
+type Node struct {
+	// ID is the index of the node in Graph.Nodes.
+	ID int
+
+	Package *internal.Package
+	File    *internal.File
+	Decl    *internal.Decl
+}
+
+// Provenance is an import of File, or a //go:linkname dependency, an edge is
+// made of, with the declarations referenced through it the edge points at.
+type Provenance struct {
+	File   *internal.File
+	Import *internal.Import
+	Decls  []*internal.Decl
+}
+
+// Uses returns the use sites of the declarations of the provenance ordered by
+// position.
+func (prov *Provenance) Uses() []*internal.Use {
+	uses := make([]*internal.Use, 0)
+	for _, use := range prov.Import.Uses {
+		if !slices.Contains(prov.Decls, use.Decl) {
+			continue
+		}
+		uses = append(uses, use)
+	}
+	slices.SortStableFunc(uses, func(a, b *internal.Use) int {
+		if c := cmp.Compare(a.Pos.Filename, b.Pos.Filename); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Pos.Line, b.Pos.Line); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Pos.Column, b.Pos.Column)
+	})
+	return uses
+}
+
+// String describes the provenance with module relative positions, e.g.
+// b/b.go:3:8 imports a, used at b/b.go:7:4 (a.Fn).
+func (prov *Provenance) String() string {
+	moduleRoot := ""
+	if prov.File.Package != nil {
+		moduleRoot = prov.File.Package.ModuleRoot
+	}
+	at := internal.ModuleRelativePosition(moduleRoot, prov.Import.Pos)
+	if at == "" {
+		at = prov.File.ModuleRelativePath()
+	}
+	imported := prov.Import.Path
+	if prov.Import.Package != nil {
+		imported = prov.Import.Package.ModuleRelativePath()
+	}
+
+	if prov.Import.IsLinkname {
+		// the directive is the use site
+		names := make([]string, 0, len(prov.Decls))
+		for _, decl := range prov.Decls {
+			names = append(names, qualifiedName(decl))
+		}
+		return at + " links " + imported + " (" + strings.Join(names, ", ") + ")"
+	}
+	desc := at + " imports " + imported
+	uses := prov.Uses()
+	if len(uses) == 0 {
+		return desc
+	}
+	sites := make([]string, 0, len(uses))
+	for _, use := range uses {
+		site := internal.ModuleRelativePosition(moduleRoot, use.Pos)
+		if site == "" {
+			continue
+		}
+		sites = append(sites, site+" ("+qualifiedName(use.Decl)+")")
+	}
+	if len(sites) == 0 {
+		return desc
+	}
+	return desc + ", used at " + strings.Join(sites, ", ")
+}
+
+func qualifiedName(decl *internal.Decl) string {
+	if decl.File == nil || decl.File.Package == nil {
+		return decl.QualifiedName()
+	}
+	return decl.File.Package.Name + "." + decl.QualifiedName()
+}
+
+// Edge aggregates every import from one node to another.
+type Edge struct {
+	From, To *Node
+
+	// Provenance is ordered by the file and path of the import.
+	Provenance []*Provenance
+}
+
+// Decls returns every declaration referenced through the edge ordered by
+// name.
+func (e *Edge) Decls() []*internal.Decl {
+	seen := make(map[*internal.Decl]struct{})
+	decls := make([]*internal.Decl, 0)
+	for _, prov := range e.Provenance {
+		for _, decl := range prov.Decls {
+			if _, ok := seen[decl]; ok {
+				continue
+			}
+			seen[decl] = struct{}{}
+			decls = append(decls, decl)
+		}
+	}
+	slices.SortFunc(decls, func(a, b *internal.Decl) int {
+		return cmp.Compare(a.QualifiedName(), b.QualifiedName())
+	})
+	return decls
+}
+
+// TestOnly reports whether every import of the edge is made from a _test.go
+// file.
+func (e *Edge) TestOnly() bool {
+	for _, prov := range e.Provenance {
+		if !prov.File.IsTest() {
+			return false
+		}
+	}
+	return true
+}
+
+// Blank reports whether every import of the edge is a blank import.
+func (e *Edge) Blank() bool {
+	for _, prov := range e.Provenance {
+		if !prov.Import.IsBlankImport() {
+			return false
+		}
+	}
+	return true
+}
+
+// Linkname reports whether any dependency of the edge is made by a
+// //go:linkname directive.
+func (e *Edge) Linkname() bool {
+	for _, prov := range e.Provenance {
+		if prov.Import.IsLinkname {
+			return true
+		}
+	}
+	return false
+}
+
+// Graph is a dependency graph of packages, files, or declarations. Nodes are
+// ordered by UID and edges by the IDs of the nodes they connect so every
+// traversal is deterministic.
+type Graph struct {
+	Level Level
+
+	nodes     []*Node
+	byPackage map[*internal.Package]*Node
+	byFile    map[*internal.File]*Node
+	byDecl    map[*internal.Decl]*Node
+
+	out    [][]*Edge
+	in     [][]*Edge
+	byEnds map[[2]int]*Edge
+}
+
+func newGraph(level Level) *Graph {
+	return &Graph{
+		Level:     level,
+		byPackage: make(map[*internal.Package]*Node),
+		byFile:    make(map[*internal.File]*Node),
+		byDecl:    make(map[*internal.Decl]*Node),
+		byEnds:    make(map[[2]int]*Edge),
+	}
+}
+
+// Packages builds the package graph of pkgs. With config.DeclarationEdges
+// only imports with a referenced declaration are edges, with
+// config.ImportEdges every import is. Imports of packages not in pkgs are not
+// edges.
+func Packages(pkgs []*internal.Package, edges config.Edges) *Graph {
+	g := newGraph(PackageLevel)
+	sorted := sortedPackages(pkgs)
+	for _, pkg := range sorted {
+		g.byPackage[pkg] = g.addNode(&Node{Package: pkg})
+	}
+	g.allocEdges()
+
+	for _, pkg := range sorted {
+		from := g.byPackage[pkg]
+		for _, file := range sortedFiles(pkg) {
+			for _, imp := range sortedImports(file) {
+				if imp.Package == nil {
+					continue
+				}
+				if edges != config.ImportEdges && len(imp.ReferencedTypes) == 0 {
+					continue
+				}
+				to, ok := g.byPackage[imp.Package]
+				if !ok {
+					continue
+				}
+				g.addProvenance(from, to, &Provenance{
+					File:   file,
+					Import: imp,
+					Decls:  referencedDecls(imp),
+				})
+			}
+		}
+	}
+	g.sortEdges()
+	return g
+}
+
+// Files builds the file graph of pkgs, a file has an edge to every file
+// declaring a declaration it references.
+func Files(pkgs []*internal.Package) *Graph {
+	g := newGraph(FileLevel)
+	files := allFiles(pkgs)
+	for _, file := range files {
+		g.byFile[file] = g.addNode(&Node{Package: file.Package, File: file})
+	}
+	g.allocEdges()
+
+	for _, file := range files {
+		from := g.byFile[file]
+		for _, imp := range sortedImports(file) {
+			byTo := make(map[*Node]*Provenance)
+			for _, decl := range referencedDecls(imp) {
+				to, ok := g.byFile[decl.File]
+				if !ok {
+					continue
+				}
+				prov, ok := byTo[to]
+				if !ok {
+					prov = &Provenance{File: file, Import: imp}
+					byTo[to] = prov
+					g.addProvenance(from, to, prov)
+				}
+				prov.Decls = append(prov.Decls, decl)
+			}
+		}
+	}
+	g.sortEdges()
+	return g
+}
+
+// Decls builds the declaration graph of pkgs, a file has an edge to every
+// declaration it references.
+func Decls(pkgs []*internal.Package) *Graph {
+	g := newGraph(DeclLevel)
+	files := allFiles(pkgs)
+	for _, file := range files {
+		g.byFile[file] = g.addNode(&Node{Package: file.Package, File: file})
+		for _, decl := range sortedFileDecls(file) {
+			g.byDecl[decl] = g.addNode(&Node{Package: file.Package, File: file, Decl: decl})
+		}
+	}
+	g.allocEdges()
+
+	for _, file := range files {
+		from := g.byFile[file]
+		for _, imp := range sortedImports(file) {
+			for _, decl := range referencedDecls(imp) {
+				to, ok := g.byDecl[decl]
+				if !ok {
+					continue
+				}
+				g.addProvenance(from, to, &Provenance{
+					File:   file,
+					Import: imp,
+					Decls:  []*internal.Decl{decl},
+				})
+			}
+		}
+	}
+	g.sortEdges()
+	return g
+}
+
+func (g *Graph) addNode(node *Node) *Node {
+	node.ID = len(g.nodes)
+	g.nodes = append(g.nodes, node)
+	return node
+}
+
+func (g *Graph) allocEdges() {
+	g.out = make([][]*Edge, len(g.nodes))
+	g.in = make([][]*Edge, len(g.nodes))
+}
+
+func (g *Graph) addProvenance(from, to *Node, prov *Provenance) {
+	e := g.Edge(from, to)
+	if e == nil {
+		e = &Edge{From: from, To: to}
+		g.byEnds[[2]int{from.ID, to.ID}] = e
+		g.out[from.ID] = append(g.out[from.ID], e)
+		g.in[to.ID] = append(g.in[to.ID], e)
+	}
+	e.Provenance = append(e.Provenance, prov)
+}
+
+func (g *Graph) sortEdges() {
+	for _, edges := range g.out {
+		slices.SortFunc(edges, func(a, b *Edge) int {
+			return cmp.Compare(a.To.ID, b.To.ID)
+		})
+	}
+	for _, edges := range g.in {
+		slices.SortFunc(edges, func(a, b *Edge) int {
+			return cmp.Compare(a.From.ID, b.From.ID)
+		})
+	}
+}
+
+// Nodes returns every node ordered by ID.
+func (g *Graph) Nodes() []*Node {
+	return g.nodes
+}
+
+// PackageNode returns the node of pkg in a package graph, nil if it is not a
+// node.
+func (g *Graph) PackageNode(pkg *internal.Package) *Node {
+	return g.byPackage[pkg]
+}
+
+// FileNode returns the node of file in a file or declaration graph, nil if
+// it is not a node.
+func (g *Graph) FileNode(file *internal.File) *Node {
+	return g.byFile[file]
+}
+
+// DeclNode returns the node of decl in a declaration graph, nil if it is not
+// a node.
+func (g *Graph) DeclNode(decl *internal.Decl) *Node {
+	return g.byDecl[decl]
+}
+
+// Edge returns the edge from one node to another, nil if there is none.
+func (g *Graph) Edge(from, to *Node) *Edge {
+	return g.byEnds[[2]int{from.ID, to.ID}]
+}
+
+// Edges returns every edge ordered by the nodes it connects.
+func (g *Graph) Edges() []*Edge {
+	edges := make([]*Edge, 0)
+	for _, out := range g.out {
+		edges = append(edges, out...)
+	}
+	return edges
+}
+
+// Out returns the edges from node ordered by the node they point at.
+func (g *Graph) Out(node *Node) []*Edge {
+	return g.out[node.ID]
+}
+
+// In returns the edges to node ordered by the node they point from.
+func (g *Graph) In(node *Node) []*Edge {
+	return g.in[node.ID]
+}
+
+// Successors returns the nodes node has an edge to.
+func (g *Graph) Successors(node *Node) []*Node {
+	succs := make([]*Node, 0, len(g.out[node.ID]))
+	for _, e := range g.out[node.ID] {
+		succs = append(succs, e.To)
+	}
+	return succs
+}
+
+// Predecessors returns the nodes with an edge to node.
+func (g *Graph) Predecessors(node *Node) []*Node {
+	preds := make([]*Node, 0, len(g.in[node.ID]))
+	for _, e := range g.in[node.ID] {
+		preds = append(preds, e.From)
+	}
+	return preds
+}
+
+// Reachable returns the nodes reachable from the given nodes, including
+// them, ordered by ID. Only edges follow returns true for are followed, every
+// edge when follow is nil.
+func (g *Graph) Reachable(follow func(e *Edge) bool, from ...*Node) []*Node {
+	reachable := make([]bool, len(g.nodes))
+	queue := make([]*Node, 0, len(from))
+	for _, node := range from {
+		if reachable[node.ID] {
+			continue
+		}
+		reachable[node.ID] = true
+		queue = append(queue, node)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, e := range g.out[node.ID] {
+			if reachable[e.To.ID] {
+				continue
+			}
+			if follow != nil && !follow(e) {
+				continue
+			}
+			reachable[e.To.ID] = true
+			queue = append(queue, e.To)
+		}
+	}
+
+	nodes := make([]*Node, 0)
+	for id, ok := range reachable {
+		if ok {
+			nodes = append(nodes, g.nodes[id])
+		}
+	}
+	return nodes
+}
+
+// Components returns the strongly connected components of the graph in
+// reverse topological order, the nodes of each component ordered by ID.
+func (g *Graph) Components() [][]*Node {
+	indexComponents := StronglyConnectedComponents(len(g.nodes), g.successorIDs)
+	components := make([][]*Node, 0, len(indexComponents))
+	for _, members := range indexComponents {
+		slices.Sort(members)
+		component := make([]*Node, 0, len(members))
+		for _, id := range members {
+			component = append(component, g.nodes[id])
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// IsCycle reports whether component, as returned by Components, is an import
+// cycle, i.e. has more than one node or a node with an edge to itself.
+func (g *Graph) IsCycle(component []*Node) bool {
+	return len(component) > 1 || g.Edge(component[0], component[0]) != nil
+}
+
+// Cycles returns the elementary cycles of the graph, see ElementaryCycles.
+func (g *Graph) Cycles(limit int) ([][]*Node, bool) {
+	indexCycles, limitReached := ElementaryCycles(len(g.nodes), g.successorIDs, limit)
+	cycles := make([][]*Node, 0, len(indexCycles))
+	for _, indexCycle := range indexCycles {
+		c := make([]*Node, 0, len(indexCycle))
+		for _, id := range indexCycle {
+			c = append(c, g.nodes[id])
+		}
+		cycles = append(cycles, c)
+	}
+	return cycles, limitReached
+}
+
+// FeedbackEdges returns edges such that every cycle of cycles, as returned
+// by Cycles, has one of them, weighted by weight, see FeedbackArcSet.
+func (g *Graph) FeedbackEdges(cycles [][]*Node, weight func(e *Edge) int) []*Edge {
+	indexCycles := make([][]int, 0, len(cycles))
+	for _, c := range cycles {
+		indexCycle := make([]int, 0, len(c))
+		for _, node := range c {
+			indexCycle = append(indexCycle, node.ID)
+		}
+		indexCycles = append(indexCycles, indexCycle)
+	}
+	arcs := FeedbackArcSet(indexCycles, func(arc Arc) int {
+		return weight(g.byEnds[[2]int{arc.From, arc.To}])
+	})
+	edges := make([]*Edge, 0, len(arcs))
+	for _, arc := range arcs {
+		edges = append(edges, g.byEnds[[2]int{arc.From, arc.To}])
+	}
+	return edges
+}
+
+func (g *Graph) successorIDs(id int) []int {
+	succs := make([]int, 0, len(g.out[id]))
+	for _, e := range g.out[id] {
+		succs = append(succs, e.To.ID)
+	}
+	return succs
+}
+
+func sortedPackages(pkgs []*internal.Package) []*internal.Package {
+	sorted := slices.Clone(pkgs)
+	slices.SortFunc(sorted, func(a, b *internal.Package) int {
+		return cmp.Compare(a.UID(), b.UID())
+	})
+	return sorted
+}
+
+func allFiles(pkgs []*internal.Package) []*internal.File {
+	files := make([]*internal.File, 0)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+	}
+	slices.SortFunc(files, func(a, b *internal.File) int {
+		return cmp.Compare(a.UID(), b.UID())
+	})
+	return files
+}
+
+func sortedFiles(pkg *internal.Package) []*internal.File {
+	files := make([]*internal.File, 0, len(pkg.Files))
+	for _, file := range pkg.Files {
+		files = append(files, file)
+	}
+	slices.SortFunc(files, func(a, b *internal.File) int {
+		return cmp.Compare(a.UID(), b.UID())
+	})
+	return files
+}
+
+func sortedImports(file *internal.File) []*internal.Import {
+	imports := make([]*internal.Import, 0, len(file.Imports))
+	for _, imp := range file.Imports {
+		imports = append(imports, imp)
+	}
+	slices.SortFunc(imports, func(a, b *internal.Import) int {
+		if c := cmp.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UID(), b.UID())
+	})
+	return imports
+}
+
+func sortedFileDecls(file *internal.File) []*internal.Decl {
+	decls := make([]*internal.Decl, 0, len(file.Decls))
+	for _, decl := range file.Decls {
+		decls = append(decls, decl)
+	}
+	slices.SortFunc(decls, func(a, b *internal.Decl) int {
+		return cmp.Compare(a.QualifiedName(), b.QualifiedName())
+	})
+	return decls
+}
+
+func referencedDecls(imp *internal.Import) []*internal.Decl {
+	decls := make([]*internal.Decl, 0, len(imp.ReferencedTypes))
+	for _, decl := range imp.ReferencedTypes {
+		decls = append(decls, decl)
+	}
+	slices.SortFunc(decls, func(a, b *internal.Decl) int {
+		return cmp.Compare(a.QualifiedName(), b.QualifiedName())
+	})
+	return decls
+}
@@ -0,0 +1,170 @@
+package graph_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+// testModule is a -> b -> c -> a through declarations, a imports d without
+// referencing it, and c imports e only from a _test.go file.
+func testModule() map[string]*internal.Package {
+	moduleRoot := filepath.FromSlash("/tmp/m")
+	pkgs := make(map[string]*internal.Package)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		pkg := &internal.Package{
+			DirName:    filepath.Join(moduleRoot, name),
+			ModulePath: "example.com/m",
+			ModuleRoot: moduleRoot,
+			Name:       name,
+			Files:      make(map[string]*internal.File),
+		}
+		file := &internal.File{
+			Package:  pkg,
+			FileName: name + ".go",
+			AbsPath:  filepath.Join(pkg.DirName, name+".go"),
+			Imports:  make(map[string]*internal.Import),
+			Decls:    make(map[string]*internal.Decl),
+		}
+		file.Decls["F"] = &internal.Decl{File: file, Name: "F"}
+		pkg.Files[file.UID()] = file
+		pkgs[name] = pkg
+	}
+	fileOf := func(pkg *internal.Package) *internal.File {
+		for _, file := range pkg.Files {
+			return file
+		}
+		return nil
+	}
+	addImport := func(from *internal.File, to string, referenced bool) {
+		imp := &internal.Import{
+			Package:         pkgs[to],
+			Name:            to,
+			Path:            "example.com/m/" + to,
+			ReferencedTypes: make(map[string]*internal.Decl),
+		}
+		if referenced {
+			imp.ReferencedTypes["F"] = fileOf(pkgs[to]).Decls["F"]
+		}
+		from.Imports[imp.UID()] = imp
+	}
+	addImport(fileOf(pkgs["a"]), "b", true)
+	addImport(fileOf(pkgs["a"]), "d", false)
+	addImport(fileOf(pkgs["b"]), "c", true)
+	addImport(fileOf(pkgs["c"]), "a", true)
+
+	test := &internal.File{
+		Package:  pkgs["c"],
+		FileName: "c_test.go",
+		AbsPath:  filepath.Join(pkgs["c"].DirName, "c_test.go"),
+		Kind:     internal.InPackageTestFile,
+		Imports:  make(map[string]*internal.Import),
+		Decls:    make(map[string]*internal.Decl),
+	}
+	pkgs["c"].Files[test.UID()] = test
+	addImport(test, "e", true)
+	return pkgs
+}
+
+func values(pkgs map[string]*internal.Package) []*internal.Package {
+	list := make([]*internal.Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		list = append(list, pkg)
+	}
+	return list
+}
+
+func names(nodes []*graph.Node) []string {
+	var names []string
+	for _, node := range nodes {
+		name := node.Package.Name
+		if node.Decl != nil {
+			name += "." + node.Decl.QualifiedName()
+		} else if node.File != nil {
+			name = node.File.FileName
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestPackages(t *testing.T) {
+	pkgs := testModule()
+
+	g := graph.Packages(values(pkgs), config.DeclarationEdges)
+	a := g.PackageNode(pkgs["a"])
+	if actual := names(g.Successors(a)); !slices.Equal([]string{"b"}, actual) {
+		t.Errorf("declaration edges: successors of a: expected [b], got %v", actual)
+	}
+	if actual := names(g.Predecessors(a)); !slices.Equal([]string{"c"}, actual) {
+		t.Errorf("predecessors of a: expected [c], got %v", actual)
+	}
+
+	g = graph.Packages(values(pkgs), config.ImportEdges)
+	a = g.PackageNode(pkgs["a"])
+	if actual := names(g.Successors(a)); !slices.Equal([]string{"b", "d"}, actual) {
+		t.Errorf("import edges: successors of a: expected [b d], got %v", actual)
+	}
+	e := g.Edge(a, g.PackageNode(pkgs["d"]))
+	if e == nil || len(e.Provenance) != 1 || len(e.Decls()) != 0 {
+		t.Errorf("a -> d: expected a single import without declarations, got %v", e)
+	}
+
+	expected := []string{"a", "b", "c", "d", "e"}
+	if actual := names(g.Reachable(nil, a)); !slices.Equal(expected, actual) {
+		t.Errorf("reachable from a: expected %v, got %v", expected, actual)
+	}
+	production := func(e *graph.Edge) bool { return !e.TestOnly() }
+	expected = []string{"a", "b", "c", "d"}
+	if actual := names(g.Reachable(production, a)); !slices.Equal(expected, actual) {
+		t.Errorf("reachable from a without tests: expected %v, got %v", expected, actual)
+	}
+
+	var cycles [][]string
+	for _, component := range g.Components() {
+		if g.IsCycle(component) {
+			cycles = append(cycles, names(component))
+		}
+	}
+	if len(cycles) != 1 || !slices.Equal([]string{"a", "b", "c"}, cycles[0]) {
+		t.Errorf("components: expected [[a b c]], got %v", cycles)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	pkgs := testModule()
+	g := graph.Files(values(pkgs))
+
+	var edges []string
+	for _, e := range g.Edges() {
+		edges = append(edges, e.From.File.FileName+" -> "+e.To.File.FileName)
+	}
+	expected := []string{"a.go -> b.go", "b.go -> c.go", "c.go -> a.go", "c_test.go -> e.go"}
+	if !slices.Equal(expected, edges) {
+		t.Errorf("edges: expected %v, got %v", expected, edges)
+	}
+
+	cycles, _ := g.Cycles(0)
+	if len(cycles) != 1 || !slices.Equal([]string{"a.go", "b.go", "c.go"}, names(cycles[0])) {
+		t.Errorf("cycles: expected [[a.go b.go c.go]], got %v", cycles)
+	}
+}
+
+func TestDecls(t *testing.T) {
+	pkgs := testModule()
+	g := graph.Decls(values(pkgs))
+
+	for _, file := range pkgs["a"].Files {
+		node := g.DeclNode(file.Decls["F"])
+		if actual := names(g.Predecessors(node)); !slices.Equal([]string{"c.go"}, actual) {
+			t.Errorf("predecessors of a.F: expected [c.go], got %v", actual)
+		}
+		if actual := names(g.Successors(g.FileNode(file))); !slices.Equal([]string{"b.F"}, actual) {
+			t.Errorf("successors of a.go: expected [b.F], got %v", actual)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package graph
+
+// StronglyConnectedComponents returns the strongly connected components of the
+// graph made of the nodes 0 through n-1 using Tarjan's algorithm. The
+// traversal is iterative so very deep graphs do not exhaust the stack.
+// Components are returned in reverse topological order and the nodes of each
+// component are in the order they were removed from the Tarjan stack.
+func StronglyConnectedComponents(n int, successors func(node int) []int) [][]int {
+	const unvisited = -1
+
+	index := make([]int, n)
+	lowLink := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = unvisited
+	}
+
+	type frame struct {
+		node  int
+		succs []int
+		next  int
+	}
+
+	var components [][]int
+	var stack []int
+	var callStack []*frame
+	nextIndex := 0
+
+	visit := func(node int) {
+		index[node] = nextIndex
+		lowLink[node] = nextIndex
+		nextIndex++
+		stack = append(stack, node)
+		onStack[node] = true
+		callStack = append(callStack, &frame{node: node, succs: successors(node)})
+	}
+
+	for root := 0; root < n; root++ {
+		if index[root] != unvisited {
+			continue
+		}
+		visit(root)
+
+		for len(callStack) > 0 {
+			top := callStack[len(callStack)-1]
+			if top.next < len(top.succs) {
+				succ := top.succs[top.next]
+				top.next++
+				if index[succ] == unvisited {
+					visit(succ)
+					continue
+				}
+				if onStack[succ] && index[succ] < lowLink[top.node] {
+					lowLink[top.node] = index[succ]
+				}
+				continue
+			}
+
+			callStack = callStack[:len(callStack)-1]
+			if len(callStack) > 0 {
+				parent := callStack[len(callStack)-1]
+				if lowLink[top.node] < lowLink[parent.node] {
+					lowLink[parent.node] = lowLink[top.node]
+				}
+			}
+			if lowLink[top.node] != index[top.node] {
+				continue
+			}
+
+			var component []int
+			for {
+				node := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[node] = false
+				component = append(component, node)
+				if node == top.node {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	return components
+}
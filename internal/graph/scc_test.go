@@ -0,0 +1,101 @@
+package graph_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/graph"
+)
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	testCases := map[string]struct {
+		n        int
+		edges    map[int][]int
+		expected [][]int
+	}{
+		"none": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {2},
+			},
+			expected: [][]int{{2}, {1}, {0}},
+		},
+		"simple": {
+			n: 3,
+			edges: map[int][]int{
+				0: {1},
+				1: {0},
+				2: {0},
+			},
+			expected: [][]int{{0, 1}, {2}},
+		},
+		"interlinked": {
+			n: 4,
+			edges: map[int][]int{
+				0: {1},
+				1: {0, 2},
+				2: {1},
+				3: {0},
+			},
+			expected: [][]int{{0, 1, 2}, {3}},
+		},
+		"independent": {
+			n: 5,
+			edges: map[int][]int{
+				0: {1},
+				1: {0},
+				2: {3},
+				3: {2},
+				4: {0, 2},
+			},
+			expected: [][]int{{0, 1}, {2, 3}, {4}},
+		},
+		"self loop": {
+			n: 1,
+			edges: map[int][]int{
+				0: {0},
+			},
+			expected: [][]int{{0}},
+		},
+	}
+
+	for testCase, tc := range testCases {
+		actual := graph.StronglyConnectedComponents(
+			tc.n,
+			func(node int) []int {
+				return tc.edges[node]
+			},
+		)
+		for _, component := range actual {
+			slices.Sort(component)
+		}
+		if len(actual) != len(tc.expected) {
+			t.Fatalf("%s: expected %d components, got %d: %v", testCase, len(tc.expected), len(actual), actual)
+		}
+		for i := range tc.expected {
+			if !slices.Equal(tc.expected[i], actual[i]) {
+				t.Errorf("%s: component %d: expected %v, got %v", testCase, i, tc.expected[i], actual[i])
+			}
+		}
+	}
+}
+
+func TestStronglyConnectedComponents_DeepChain(t *testing.T) {
+	n := 1_000_000
+	actual := graph.StronglyConnectedComponents(
+		n,
+		func(node int) []int {
+			if node == n-1 {
+				return []int{0}
+			}
+			return []int{node + 1}
+		},
+	)
+	if len(actual) != 1 {
+		t.Fatalf("expected 1 component, got %d", len(actual))
+	}
+	if len(actual[0]) != n {
+		t.Fatalf("expected component of %d nodes, got %d", n, len(actual[0]))
+	}
+}
@@ -0,0 +1,31 @@
+package pkgname
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ModulePathNotFoundError struct {
+	GoModFile string
+}
+
+func (err *ModulePathNotFoundError) Error() string {
+	return fmt.Sprintf("go module path not found: %s", err.GoModFile)
+}
+
+type AmbiguousNameError struct {
+	ImportPath string
+	Dir        string
+	Names      []string
+	Chosen     string
+}
+
+func (err *AmbiguousNameError) Error() string {
+	return fmt.Sprintf(
+		"ambiguous package name for %s: %s declares %s, using %s",
+		err.ImportPath,
+		err.Dir,
+		strings.Join(err.Names, ", "),
+		err.Chosen,
+	)
+}
@@ -0,0 +1,285 @@
+package pkgname
+
+import (
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// Resolver resolves the name declared by the package clause of an imported
+// package. In-module packages are read from the module root, other packages
+// from the vendor directory, local replacements, the module cache, or
+// GOROOT, in that order. Packages which cannot be found are assumed to be
+// named after their import path, see AssumedName. When a directory declares
+// more than one package name the assumed name is preferred, otherwise the
+// first name in lexical order, and an AmbiguousNameError is recorded.
+type Resolver struct {
+	modulePath    string
+	moduleRootDir string
+	modCacheDir   string
+	goRootDir     string
+
+	buildContext *build.Context
+	requires     []module.Version
+	replaces     []*modfile.Replace
+
+	mu                sync.Mutex
+	namesByImportPath map[string]string
+	diagnostics       []*AmbiguousNameError
+}
+
+func NewResolver(goModFile string, buildContext *build.Context) (*Resolver, error) {
+	data, err := os.ReadFile(goModFile)
+	if err != nil {
+		return nil, err
+	}
+	goMod, err := modfile.Parse(goModFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if goMod.Module == nil {
+		return nil, &ModulePathNotFoundError{GoModFile: goModFile}
+	}
+
+	r := &Resolver{
+		modulePath:        goMod.Module.Mod.Path,
+		moduleRootDir:     filepath.Dir(goModFile),
+		modCacheDir:       modCacheDir(),
+		goRootDir:         buildContext.GOROOT,
+		buildContext:      buildContext,
+		requires:          make([]module.Version, 0, len(goMod.Require)),
+		replaces:          goMod.Replace,
+		namesByImportPath: make(map[string]string),
+	}
+	for _, require := range goMod.Require {
+		r.requires = append(r.requires, require.Mod)
+	}
+	return r, nil
+}
+
+func (r *Resolver) PackageName(importPath string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if name, ok := r.namesByImportPath[importPath]; ok {
+		return name
+	}
+	name := r.resolve(importPath)
+	r.namesByImportPath[importPath] = name
+	return name
+}
+
+// Diagnostics returns an error for every import path which resolved to a
+// directory declaring more than one package name, ordered by import path as
+// packages may be resolved concurrently.
+func (r *Resolver) Diagnostics() []*AmbiguousNameError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	diagnostics := slices.Clone(r.diagnostics)
+	slices.SortFunc(diagnostics, func(a, b *AmbiguousNameError) int {
+		return strings.Compare(a.ImportPath, b.ImportPath)
+	})
+	return diagnostics
+}
+
+func (r *Resolver) resolve(importPath string) string {
+	assumed := AssumedName(importPath)
+	for _, dir := range r.candidateDirs(importPath) {
+		names := r.packageNames(dir)
+		if len(names) == 0 {
+			continue
+		}
+		if len(names) == 1 {
+			return names[0]
+		}
+		chosen := names[0]
+		if slices.Contains(names, assumed) {
+			chosen = assumed
+		}
+		r.diagnostics = append(r.diagnostics, &AmbiguousNameError{
+			ImportPath: importPath,
+			Dir:        dir,
+			Names:      names,
+			Chosen:     chosen,
+		})
+		return chosen
+	}
+	return assumed
+}
+
+func (r *Resolver) candidateDirs(importPath string) []string {
+	dirs := make([]string, 0, 4)
+
+	if rel, ok := trimModulePath(importPath, r.modulePath); ok {
+		return append(dirs, filepath.Join(r.moduleRootDir, filepath.FromSlash(rel)))
+	}
+
+	dirs = append(dirs, filepath.Join(r.moduleRootDir, "vendor", filepath.FromSlash(importPath)))
+
+	var replace *modfile.Replace
+	for _, candidate := range r.replaces {
+		if _, ok := trimModulePath(importPath, candidate.Old.Path); !ok {
+			continue
+		}
+		if replace != nil && len(replace.Old.Path) >= len(candidate.Old.Path) {
+			continue
+		}
+		replace = candidate
+	}
+	if replace != nil {
+		rel, _ := trimModulePath(importPath, replace.Old.Path)
+		if replace.New.Version == "" {
+			root := filepath.FromSlash(replace.New.Path)
+			if !filepath.IsAbs(root) {
+				root = filepath.Join(r.moduleRootDir, root)
+			}
+			return append(dirs, filepath.Join(root, filepath.FromSlash(rel)))
+		}
+		if dir, ok := r.modCachePackageDir(replace.New, rel); ok {
+			return append(dirs, dir)
+		}
+		return dirs
+	}
+
+	var require *module.Version
+	for i, candidate := range r.requires {
+		if _, ok := trimModulePath(importPath, candidate.Path); !ok {
+			continue
+		}
+		if require != nil && len(require.Path) >= len(candidate.Path) {
+			continue
+		}
+		require = &r.requires[i]
+	}
+	if require != nil {
+		rel, _ := trimModulePath(importPath, require.Path)
+		if dir, ok := r.modCachePackageDir(*require, rel); ok {
+			dirs = append(dirs, dir)
+		}
+		return dirs
+	}
+
+	if r.goRootDir != "" && !strings.Contains(strings.Split(importPath, "/")[0], ".") {
+		dirs = append(dirs, filepath.Join(r.goRootDir, "src", filepath.FromSlash(importPath)))
+	}
+	return dirs
+}
+
+func (r *Resolver) modCachePackageDir(mod module.Version, rel string) (string, bool) {
+	if r.modCacheDir == "" {
+		return "", false
+	}
+	escapedPath, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return "", false
+	}
+	escapedVersion, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(
+		r.modCacheDir,
+		escapedPath+"@"+escapedVersion,
+		filepath.FromSlash(rel),
+	), true
+}
+
+// packageNames returns the sorted, distinct, package names declared by the
+// non-test files in dir which match the build context.
+func (r *Resolver) packageNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, 1)
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		match, err := r.buildContext.MatchFile(dir, name)
+		if err != nil || !match {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err != nil || f.Name == nil {
+			continue
+		}
+		if slices.Contains(names, f.Name.Name) {
+			continue
+		}
+		names = append(names, f.Name.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// AssumedName returns the package name assumed for an import path when the
+// package cannot be read, using the heuristic goimports and gopls apply to
+// imports whose package clause is unknown. Major version suffixes such as /v2
+// are skipped, a go- prefix is removed, and the name ends at the first
+// character which is not valid in an identifier, e.g. gopkg.in/yaml.v3 is
+// yaml. Like goimports, the name is not checked to be an identifier, e.g.
+// example.com/go- is empty and example.com/9lives is 9lives, so no reference
+// matches it.
+func AssumedName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			dir := path.Dir(importPath)
+			if dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, notIdentifier); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+func notIdentifier(ch rune) bool {
+	return !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' ||
+		'0' <= ch && ch <= '9' ||
+		ch == '_' ||
+		ch >= utf8.RuneSelf && (unicode.IsLetter(ch) || unicode.IsDigit(ch)))
+}
+
+func trimModulePath(importPath, modulePath string) (string, bool) {
+	if importPath == modulePath {
+		return "", true
+	}
+	if strings.HasPrefix(importPath, modulePath+"/") {
+		return strings.TrimPrefix(importPath, modulePath+"/"), true
+	}
+	return "", false
+}
+
+func modCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	goPaths := filepath.SplitList(build.Default.GOPATH)
+	if len(goPaths) == 0 || goPaths[0] == "" {
+		return ""
+	}
+	return filepath.Join(goPaths[0], "pkg", "mod")
+}
@@ -0,0 +1,115 @@
+package pkgname_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/pkgname"
+	"github.com/samlitowitz/goimportcycle/internal/platform"
+)
+
+func TestResolver_PackageName(t *testing.T) {
+	tmpDir := t.TempDir()
+	moduleRootDir := filepath.Join(tmpDir, "m")
+	modCacheDir := filepath.Join(tmpDir, "cache")
+	t.Setenv("GOMODCACHE", modCacheDir)
+
+	files := map[string]string{
+		"m/go.mod": `module example.com/m
+
+go 1.21
+
+require (
+	example.org/lib v1.2.3
+	example.org/Upper v0.1.0
+)
+
+replace example.org/local => ./local
+`,
+		"m/renamed/x.go":                            "package other\n",
+		"m/renamed/x_test.go":                       "package other_test\n",
+		"m/ambiguous/a.go":                          "package foo\n",
+		"m/ambiguous/b.go":                          "package bar\n",
+		"m/ambiguous/c.go":                          "//go:build ignore\n\npackage main\n",
+		"m/tool/main.go":                            "package main\n",
+		"m/tool/gen.go":                             "//go:build ignore\n\npackage gen\n",
+		"m/vendor/example.net/vend/v2/v.go":         "package vend\n",
+		"m/local/sub/s.go":                          "package localsub\n",
+		"cache/example.org/lib@v1.2.3/yaml.v3/y.go": "package yaml\n",
+		"cache/example.org/!upper@v0.1.0/u.go":      "package up\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0770)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := platform.Parse("linux/amd64", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := pkgname.NewResolver(filepath.Join(moduleRootDir, "go.mod"), p.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]string{
+		"example.com/m/renamed":        "other",
+		"example.com/m/ambiguous":      "bar",
+		"example.com/m/tool":           "main",
+		"example.com/m/missing":        "missing",
+		"example.net/vend/v2":          "vend",
+		"example.org/local/sub":        "localsub",
+		"example.org/lib/yaml.v3":      "yaml",
+		"example.org/Upper":            "up",
+		"encoding/json":                "json",
+		"example.org/unknown/go-thing": "thing",
+		"example.org/unknown/v2":       "unknown",
+		"gopkg.in/unknown.v3":          "unknown",
+	}
+	for importPath, expected := range testCases {
+		actual := resolver.PackageName(importPath)
+		if actual != expected {
+			t.Errorf("%s: expected package name %s, got %s", importPath, expected, actual)
+		}
+	}
+
+	diagnostics := resolver.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(diagnostics))
+	}
+	if diagnostics[0].ImportPath != "example.com/m/ambiguous" {
+		t.Errorf("unexpected diagnostic import path: %s", diagnostics[0].ImportPath)
+	}
+	if !slices.Equal(diagnostics[0].Names, []string{"bar", "foo"}) {
+		t.Errorf("unexpected diagnostic names: %v", diagnostics[0].Names)
+	}
+}
+
+func TestAssumedName(t *testing.T) {
+	testCases := map[string]string{
+		"example.com/m/missing":  "missing",
+		"example.com/go-foo/v2":  "foo",
+		"gopkg.in/yaml.v3":       "yaml",
+		"example.com/go-kit-log": "kit",
+		"example.com/v2":         "example",
+		// what remains is not an identifier
+		"example.com/go-":    "",
+		"example.com/go-.v1": "",
+		"example.com/9lives": "9lives",
+	}
+	for importPath, expected := range testCases {
+		actual := pkgname.AssumedName(importPath)
+		if actual != expected {
+			t.Errorf("%s: expected %q, got %q", importPath, expected, actual)
+		}
+	}
+}
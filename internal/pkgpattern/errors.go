@@ -0,0 +1,26 @@
+package pkgpattern
+
+import "fmt"
+
+type OutsideModuleError struct {
+	Pattern    string
+	ModulePath string
+}
+
+func (err *OutsideModuleError) Error() string {
+	return fmt.Sprintf("pattern %s: not in module %s", err.Pattern, err.ModulePath)
+}
+
+// ImportError is an error reading the imports of the package in Dir.
+type ImportError struct {
+	Dir string
+	Err error
+}
+
+func (err *ImportError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Dir, err.Err)
+}
+
+func (err *ImportError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,207 @@
+package pkgpattern
+
+import (
+	"errors"
+	"go/build"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Set is a set of go package patterns selecting the packages of a module,
+// e.g. ./..., ./internal/..., or example.com/m/cmd/api. Relative patterns
+// are relative to the working directory. A pattern matches a directory when
+// it matches the import path of the directory, ... matches any string and a
+// trailing /... also matches the path before it, as with the go tool.
+type Set struct {
+	modulePath    string
+	moduleRootDir string
+
+	patterns []string
+	matchers []*regexp.Regexp
+}
+
+// Parse parses patterns into a Set of the packages of the module at
+// moduleRootDir.
+func Parse(modulePath, moduleRootDir, workingDir string, patterns []string) (*Set, error) {
+	set := &Set{
+		modulePath:    modulePath,
+		moduleRootDir: moduleRootDir,
+	}
+	for _, pattern := range patterns {
+		importPattern, err := set.importPattern(workingDir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		set.patterns = append(set.patterns, pattern)
+		set.matchers = append(set.matchers, compile(importPattern))
+	}
+	return set, nil
+}
+
+// importPattern converts pattern to a pattern of import paths in the module.
+func (set *Set) importPattern(workingDir, pattern string) (string, error) {
+	if !isLocal(pattern) {
+		literal, _, _ := strings.Cut(pattern, "...")
+		if !strings.HasPrefix(literal, set.modulePath) &&
+			!strings.HasPrefix(set.modulePath, literal) {
+			return "", &OutsideModuleError{Pattern: pattern, ModulePath: set.modulePath}
+		}
+		return pattern, nil
+	}
+
+	dir := pattern
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workingDir, dir)
+	}
+	rel, err := filepath.Rel(set.moduleRootDir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &OutsideModuleError{Pattern: pattern, ModulePath: set.modulePath}
+	}
+	if rel == "." {
+		return set.modulePath, nil
+	}
+	return set.modulePath + "/" + filepath.ToSlash(rel), nil
+}
+
+// Match reports whether the package in the directory dir of the module is
+// matched by a pattern.
+func (set *Set) Match(dir string) bool {
+	importPath := set.importPath(dir)
+	for _, matcher := range set.matchers {
+		if matcher.MatchString(importPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmatched returns the patterns matching none of dirs.
+func (set *Set) Unmatched(dirs []string) []string {
+	var unmatched []string
+	for i, matcher := range set.matchers {
+		matched := slices.ContainsFunc(dirs, func(dir string) bool {
+			return matcher.MatchString(set.importPath(dir))
+		})
+		if !matched {
+			unmatched = append(unmatched, set.patterns[i])
+		}
+	}
+	return unmatched
+}
+
+// Closure returns, in lexical order, the directories of dirs matched by a
+// pattern and the directories of dirs they transitively import. Imports are
+// read with buildContext, test imports are followed when tests is set. A
+// directory whose imports cannot be read is passed to skip as an
+// *ImportError, it is left out of the closure when skip returns nil and
+// Closure stops with the error returned otherwise, every error stops Closure
+// when skip is nil.
+func (set *Set) Closure(
+	dirs []string,
+	buildContext *build.Context,
+	tests bool,
+	skip func(err error) error,
+) ([]string, error) {
+	dirsByImportPath := make(map[string]string, len(dirs))
+	for _, dir := range dirs {
+		dirsByImportPath[set.importPath(dir)] = dir
+	}
+
+	inClosure := make(map[string]struct{})
+	skipped := make(map[string]struct{})
+	var queue []string
+	for _, dir := range dirs {
+		if set.Match(dir) {
+			inClosure[dir] = struct{}{}
+			queue = append(queue, dir)
+		}
+	}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		imports, err := dirImports(buildContext, dir, tests)
+		if err != nil {
+			if skip == nil {
+				return nil, err
+			}
+			if err := skip(err); err != nil {
+				return nil, err
+			}
+			delete(inClosure, dir)
+			skipped[dir] = struct{}{}
+			continue
+		}
+		for _, importPath := range imports {
+			importedDir, ok := dirsByImportPath[importPath]
+			if !ok {
+				continue
+			}
+			if _, ok := inClosure[importedDir]; ok {
+				continue
+			}
+			if _, ok := skipped[importedDir]; ok {
+				continue
+			}
+			inClosure[importedDir] = struct{}{}
+			queue = append(queue, importedDir)
+		}
+	}
+
+	closure := make([]string, 0, len(inClosure))
+	for dir := range inClosure {
+		closure = append(closure, dir)
+	}
+	slices.Sort(closure)
+	return closure, nil
+}
+
+func (set *Set) importPath(dir string) string {
+	rel, err := filepath.Rel(set.moduleRootDir, dir)
+	if err != nil || rel == "." {
+		return set.modulePath
+	}
+	return set.modulePath + "/" + filepath.ToSlash(rel)
+}
+
+// dirImports returns the imports of the files in dir selected by
+// buildContext.
+func dirImports(buildContext *build.Context, dir string, tests bool) ([]string, error) {
+	pkg, err := buildContext.ImportDir(dir, 0)
+	if err != nil {
+		var noGoErr *build.NoGoError
+		if errors.As(err, &noGoErr) {
+			return nil, nil
+		}
+		// the imports of every package in the directory are read anyway
+		var multiErr *build.MultiplePackageError
+		if !errors.As(err, &multiErr) {
+			return nil, &ImportError{Dir: dir, Err: err}
+		}
+	}
+	imports := slices.Clone(pkg.Imports)
+	if tests {
+		imports = append(imports, pkg.TestImports...)
+		imports = append(imports, pkg.XTestImports...)
+	}
+	return imports, nil
+}
+
+func isLocal(pattern string) bool {
+	return pattern == "." || pattern == ".." ||
+		strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") ||
+		filepath.IsAbs(pattern)
+}
+
+// compile compiles an import path pattern the same way the go tool does.
+func compile(pattern string) *regexp.Regexp {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	// a trailing /... also matches the path before it
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	return regexp.MustCompile(`^` + re + `$`)
+}
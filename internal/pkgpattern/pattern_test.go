@@ -0,0 +1,133 @@
+package pkgpattern_test
+
+import (
+	"errors"
+	"go/build"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/pkgpattern"
+)
+
+func TestSet_Closure(t *testing.T) {
+	moduleRootDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/m\n\ngo 1.21\n",
+		"cmd/api/main.go": "package main\n\nimport \"example.com/m/a\"\n\nfunc main() { a.A() }\n",
+		"a/a.go":          "package a\n\nimport \"example.com/m/b\"\n\nfunc A() { b.B() }\n",
+		"a/a_test.go":     "package a\n\nimport \"example.com/m/d\"\n\nvar _ = d.D\n",
+		"b/b.go":          "package b\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/m/c\"\n)\n\nfunc B() { fmt.Println(c.C) }\n",
+		"c/c.go":          "package c\n\nconst C = 1\n",
+		"d/d.go":          "package d\n\nconst D = 1\n",
+		"e/e.go":          "package e\n",
+	}
+	var dirs []string
+	for name, data := range files {
+		path := filepath.Join(moduleRootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(dirs, filepath.Dir(path)) {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+	}
+	slices.Sort(dirs)
+
+	testCases := map[string]struct {
+		workingDir string
+		patterns   []string
+		tests      bool
+		matched    []string
+		closure    []string
+		unmatched  []string
+	}{
+		"every package": {
+			patterns: []string{"./..."},
+			matched:  []string{".", "a", "b", "c", "cmd/api", "d", "e"},
+			closure:  []string{".", "a", "b", "c", "cmd/api", "d", "e"},
+		},
+		"main package": {
+			patterns: []string{"./cmd/api"},
+			matched:  []string{"cmd/api"},
+			closure:  []string{"a", "b", "c", "cmd/api"},
+		},
+		"main package with tests": {
+			patterns: []string{"./cmd/api"},
+			tests:    true,
+			matched:  []string{"cmd/api"},
+			closure:  []string{"a", "b", "c", "cmd/api", "d"},
+		},
+		"relative to the working directory": {
+			workingDir: "cmd",
+			patterns:   []string{"./...", "../e"},
+			matched:    []string{"cmd/api", "e"},
+			closure:    []string{"a", "b", "c", "cmd/api", "e"},
+		},
+		"import paths": {
+			patterns:  []string{"example.com/m/b", "example.com/m/internal/..."},
+			matched:   []string{"b"},
+			closure:   []string{"b", "c"},
+			unmatched: []string{"example.com/m/internal/..."},
+		},
+	}
+
+	for name, testCase := range testCases {
+		set, err := pkgpattern.Parse(
+			"example.com/m",
+			moduleRootDir,
+			filepath.Join(moduleRootDir, testCase.workingDir),
+			testCase.patterns,
+		)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		closure, err := set.Closure(dirs, &build.Default, testCase.tests, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		var matched []string
+		for _, dir := range dirs {
+			if set.Match(dir) {
+				matched = append(matched, relPath(t, moduleRootDir, dir))
+			}
+		}
+		if !slices.Equal(testCase.matched, matched) {
+			t.Errorf("%s: expected matched %v, got %v", name, testCase.matched, matched)
+		}
+		var actual []string
+		for _, dir := range closure {
+			actual = append(actual, relPath(t, moduleRootDir, dir))
+		}
+		if !slices.Equal(testCase.closure, actual) {
+			t.Errorf("%s: expected closure %v, got %v", name, testCase.closure, actual)
+		}
+		if unmatched := set.Unmatched(closure); !slices.Equal(testCase.unmatched, unmatched) {
+			t.Errorf("%s: expected unmatched %v, got %v", name, testCase.unmatched, unmatched)
+		}
+	}
+}
+
+func TestParse_OutsideModule(t *testing.T) {
+	moduleRootDir := t.TempDir()
+	for _, pattern := range []string{"..", "../other/...", "golang.org/x/tools/..."} {
+		_, err := pkgpattern.Parse("example.com/m", moduleRootDir, moduleRootDir, []string{pattern})
+		var outsideErr *pkgpattern.OutsideModuleError
+		if !errors.As(err, &outsideErr) {
+			t.Errorf("%s: expected OutsideModuleError, got %v", pattern, err)
+		}
+	}
+}
+
+func relPath(t *testing.T, moduleRootDir, dir string) string {
+	rel, err := filepath.Rel(moduleRootDir, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.ToSlash(rel)
+}
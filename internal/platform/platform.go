@@ -0,0 +1,113 @@
+package platform
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Platform selects the files of a package using the same build constraints,
+// `//go:build` lines and _GOOS/_GOARCH file name suffixes, as the go tool.
+type Platform struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// Default returns the platform described by the GOOS, GOARCH, and GOFLAGS
+// environment variables, falling back to the host platform.
+func Default() Platform {
+	return Platform{
+		GOOS:   build.Default.GOOS,
+		GOARCH: build.Default.GOARCH,
+		Tags:   TagsFromGoFlags(os.Getenv("GOFLAGS")),
+	}
+}
+
+// Parse parses a platform formatted as GOOS/GOARCH.
+func Parse(in string, tags []string) (Platform, error) {
+	goos, goarch, ok := strings.Cut(strings.TrimSpace(in), "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return Platform{}, fmt.Errorf("invalid platform %s, must be formatted as GOOS/GOARCH", in)
+	}
+	return Platform{
+		GOOS:   goos,
+		GOARCH: goarch,
+		Tags:   tags,
+	}, nil
+}
+
+// ParseList parses a comma separated list of platforms, see Parse.
+func ParseList(in string, tags []string) ([]Platform, error) {
+	platforms := make([]Platform, 0)
+	seen := make(map[string]struct{})
+	for _, piece := range strings.Split(in, ",") {
+		if strings.TrimSpace(piece) == "" {
+			continue
+		}
+		p, err := Parse(piece, tags)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[p.String()]; ok {
+			continue
+		}
+		seen[p.String()] = struct{}{}
+		platforms = append(platforms, p)
+	}
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("invalid platform list %s, no platforms", in)
+	}
+	return platforms, nil
+}
+
+// ParseTags parses a comma separated list of build tags. Space separated
+// lists, deprecated by the go tool, are accepted as well.
+func ParseTags(in string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.FieldsFunc(in, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		if slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// TagsFromGoFlags returns the build tags set by the -tags flag in a GOFLAGS
+// formatted string.
+func TagsFromGoFlags(goFlags string) []string {
+	fields := strings.Fields(goFlags)
+	for i, field := range fields {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(field, "-"), "=")
+		if name != "tags" || !strings.HasPrefix(field, "-") {
+			continue
+		}
+		if hasValue {
+			return ParseTags(value)
+		}
+		if i+1 < len(fields) {
+			return ParseTags(fields[i+1])
+		}
+	}
+	return []string{}
+}
+
+func (p Platform) String() string {
+	return p.GOOS + "/" + p.GOARCH
+}
+
+// Context returns a build context selecting files for the platform. Cgo is
+// enabled unless CGO_ENABLED is set to 0 so cgo files are analyzed.
+func (p Platform) Context() *build.Context {
+	ctxt := build.Default
+	ctxt.GOOS = p.GOOS
+	ctxt.GOARCH = p.GOARCH
+	ctxt.BuildTags = slices.Clone(p.Tags)
+	ctxt.CgoEnabled = os.Getenv("CGO_ENABLED") != "0"
+	return &ctxt
+}
@@ -0,0 +1,114 @@
+package platform_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/platform"
+)
+
+func TestParseList(t *testing.T) {
+	platforms, err := platform.ParseList("linux/amd64, windows/arm64,linux/amd64", []string{"integration"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"linux/amd64", "windows/arm64"}
+	if len(platforms) != len(expected) {
+		t.Fatalf("expected %d platforms, got %d", len(expected), len(platforms))
+	}
+	for i, p := range platforms {
+		if p.String() != expected[i] {
+			t.Errorf("expected platform %s, got %s", expected[i], p.String())
+		}
+		if !slices.Equal(p.Tags, []string{"integration"}) {
+			t.Errorf("%s: unexpected tags: %v", p.String(), p.Tags)
+		}
+	}
+
+	for _, invalid := range []string{"", "linux", "linux/", "/amd64", "linux/amd64/v2"} {
+		if _, err := platform.ParseList(invalid, nil); err == nil {
+			t.Errorf("expected error for platform list %q", invalid)
+		}
+	}
+}
+
+func TestTagsFromGoFlags(t *testing.T) {
+	testCases := map[string][]string{
+		"":                         {},
+		"-mod=mod":                 {},
+		"-tags=a,b":                {"a", "b"},
+		"-mod=mod --tags=a":        {"a"},
+		"-tags a,b -mod=mod":       {"a", "b"},
+		"-modcacherw -tags=a,b,a ": {"a", "b"},
+	}
+	for goFlags, expected := range testCases {
+		actual := platform.TagsFromGoFlags(goFlags)
+		if !slices.Equal(expected, actual) {
+			t.Errorf("%q: expected tags %v, got %v", goFlags, expected, actual)
+		}
+	}
+}
+
+func TestPlatform_Context_MatchFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"a.go":         "package a\n",
+		"a_linux.go":   "package a\n",
+		"a_windows.go": "package a\n",
+		"a_arm64.go":   "package a\n",
+		"ignore.go":    "//go:build ignore\n\npackage main\n",
+		"tagged.go":    "//go:build integration\n\npackage a\n",
+		"not.go":       "//go:build !linux\n\npackage a\n",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(tmpDir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := map[string]struct {
+		platform string
+		tags     []string
+		expected []string
+	}{
+		"linux": {
+			platform: "linux/amd64",
+			expected: []string{"a.go", "a_linux.go"},
+		},
+		"windows": {
+			platform: "windows/arm64",
+			expected: []string{"a.go", "a_arm64.go", "a_windows.go", "not.go"},
+		},
+		"tagged": {
+			platform: "linux/amd64",
+			tags:     []string{"integration"},
+			expected: []string{"a.go", "a_linux.go", "tagged.go"},
+		},
+	}
+	for testCase, tc := range testCases {
+		p, err := platform.Parse(tc.platform, tc.tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctxt := p.Context()
+
+		actual := make([]string, 0)
+		for name := range files {
+			match, err := ctxt.MatchFile(tmpDir, name)
+			if err != nil {
+				t.Fatalf("%s: %s", testCase, err)
+			}
+			if match {
+				actual = append(actual, name)
+			}
+		}
+		slices.Sort(actual)
+		if !slices.Equal(tc.expected, actual) {
+			t.Errorf("%s: expected files %v, got %v", testCase, tc.expected, actual)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 
 import (
 	"fmt"
+	"go/token"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,11 +17,27 @@ type Package struct {
 
 	Files map[string]*File
 
-	IsStub        bool
+	IsStub         bool
+	IsExternalTest bool
+	// OutOfScope is set when the package is not matched by the analyzed
+	// package patterns and is only analyzed as it is imported by a package
+	// which is.
+	OutOfScope    bool
 	InImportCycle bool
+	Component     *PackageComponent
 }
 
+// ImportPath returns the import path of the package, external test packages
+// are suffixed with _test as they are by the go tool.
 func (pkg Package) ImportPath() string {
+	importPath := pkg.importPath()
+	if !pkg.IsExternalTest || importPath == "" {
+		return importPath
+	}
+	return importPath + "_test"
+}
+
+func (pkg Package) importPath() string {
 	if pkg.Name == "main" {
 		return ""
 	}
@@ -45,7 +62,36 @@ func (pkg Package) ImportPath() string {
 	return pkg.Name
 }
 
+// DirImportPath returns the import path of the directory of the package, set
+// for main packages unlike ImportPath, and suffixed with _test for external
+// test packages.
+func (pkg Package) DirImportPath() string {
+	path := pkg.ModulePath
+	rel, err := filepath.Rel(pkg.ModuleRoot, pkg.DirName)
+	switch {
+	case err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)):
+		path = filepath.ToSlash(pkg.DirName)
+	case rel != ".":
+		path += "/" + filepath.ToSlash(rel)
+	}
+	if pkg.IsExternalTest {
+		path += "_test"
+	}
+	return path
+}
+
 func (pkg Package) ModuleRelativePath() string {
+	path := pkg.moduleRelativePath()
+	if !pkg.IsExternalTest {
+		return path
+	}
+	if path == "" {
+		return pkg.Name
+	}
+	return path + "_test"
+}
+
+func (pkg Package) moduleRelativePath() string {
 	if strings.HasPrefix(pkg.DirName, pkg.ModuleRoot) {
 		path := strings.TrimPrefix(
 			pkg.DirName,
@@ -85,17 +131,37 @@ func (pkg Package) UID() string {
 	return pkg.DirName
 }
 
+type FileKind int
+
+const (
+	ProductionFile FileKind = iota
+	// InPackageTestFile is a _test.go file belonging to the package under test.
+	InPackageTestFile
+	// ExternalTestFile is a _test.go file belonging to an external _test
+	// package.
+	ExternalTestFile
+)
+
 type File struct {
 	Package *Package
 
 	FileName string
 	AbsPath  string
+	Kind     FileKind
 
 	Imports map[string]*Import
 	Decls   map[string]*Decl
 
-	IsStub        bool
+	IsStub bool
+	// IsCgo is set when the file imports the cgo pseudo-package "C", which
+	// is not an import of the package graph.
+	IsCgo         bool
 	InImportCycle bool
+	Component     *FileComponent
+}
+
+func (f File) IsTest() bool {
+	return f.Kind != ProductionFile
 }
 
 func (f File) HasDecl(decl *Decl) bool {
@@ -125,6 +191,17 @@ func (f File) ReferencedFiles() []*File {
 
 }
 
+func (f File) ModuleRelativePath() string {
+	if f.Package == nil || f.Package.ModuleRoot == "" {
+		return f.FileName
+	}
+	if !strings.HasPrefix(f.AbsPath, f.Package.ModuleRoot) {
+		return f.FileName
+	}
+	path := strings.TrimPrefix(f.AbsPath, f.Package.ModuleRoot)
+	return strings.TrimPrefix(path, string(filepath.Separator))
+}
+
 func (f File) UID() string {
 	return f.AbsPath
 }
@@ -134,6 +211,9 @@ type Decl struct {
 	ReceiverDecl *Decl
 
 	Name string
+	// Pos is the position of the name of the declaration, unknown until the
+	// declaring file is added.
+	Pos token.Position
 }
 
 func (decl Decl) UID() string {
@@ -152,13 +232,85 @@ type Import struct {
 
 	Name string
 	Path string
+	// IsLinkname is set when the dependency is made by //go:linkname
+	// directives rather than an import.
+	IsLinkname bool
+	// Pos is the position of the import spec, or of the first //go:linkname
+	// directive.
+	Pos token.Position
 
 	ReferencedTypes map[string]*Decl
+	// Uses are the use sites of the referenced declarations in the order
+	// they were found.
+	Uses []*Use
 
 	InImportCycle          bool
 	ReferencedFilesInCycle map[string]*File
 }
 
+// UID is the name the import is referenced by in its file. Dot and blank
+// imports may be repeated so they are told apart by path, as are
+// //go:linkname dependencies.
 func (i Import) UID() string {
+	if i.IsLinkname {
+		return "//go:linkname " + i.Path
+	}
+	if i.IsDotImport() || i.IsBlankImport() {
+		return i.Name + "\"" + i.Path + "\""
+	}
 	return i.Name
 }
+
+// IsBlankImport reports whether the import is only for the imported
+// package's side effects, e.g. import _ "embed".
+func (i Import) IsBlankImport() bool {
+	return i.Name == "_"
+}
+
+// IsDotImport reports whether the imported package's declarations are
+// referenced without a qualifier, e.g. import . "fmt".
+func (i Import) IsDotImport() bool {
+	return i.Name == "."
+}
+
+// Use is a use site of a declaration referenced through an import.
+type Use struct {
+	Decl *Decl
+	Pos  token.Position
+}
+
+// ModuleRelativePosition formats pos as path:line:column with the path
+// relative to moduleRoot, the path alone when only the file of pos is known,
+// and empty when pos is unknown.
+func ModuleRelativePosition(moduleRoot string, pos token.Position) string {
+	if pos.Filename == "" {
+		return ""
+	}
+	path := pos.Filename
+	if moduleRoot != "" && strings.HasPrefix(path, moduleRoot) {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, moduleRoot), string(filepath.Separator))
+	}
+	if !pos.IsValid() {
+		return filepath.ToSlash(path)
+	}
+	return fmt.Sprintf("%s:%d:%d", filepath.ToSlash(path), pos.Line, pos.Column)
+}
+
+// PackageComponent is a strongly connected component of the package graph.
+// Every package in a component with more than one member, or with an edge to
+// itself, is part of an import cycle.
+type PackageComponent struct {
+	ID       int
+	Packages []*Package
+
+	IsCycle bool
+}
+
+// FileComponent is a strongly connected component of the file graph, see
+// PackageComponent.
+type FileComponent struct {
+	ID    int
+	Files []*File
+
+	IsCycle bool
+}
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/cycle"
+)
+
+// CyclePaths returns the paths of the import cycles at the resolution of
+// cfg, e.g. a -> b -> a, marked (test only) like the import cycle report.
+// With config.ImportEdges they are the import cycles between packages the
+// compiler rejects, marked (imports only) like the import cycle report. The
+// second return value reports whether more cycles exist.
+func CyclePaths(cfg *config.Config, pkgs []*internal.Package) ([]string, bool, error) {
+	var cycles []*cycle.Cycle
+	var limitReached bool
+	switch {
+	case cfg.Resolution != config.FileResolution && cfg.Resolution != config.PackageResolution:
+		return nil, false, fmt.Errorf("cycle paths: invalid resolution: %d", cfg.Resolution)
+	case cfg.Edges == config.ImportEdges:
+		cycles, limitReached = cycle.Imports(pkgs, cfg.MaxCycles)
+	case cfg.Resolution == config.FileResolution:
+		cycles, limitReached = cycle.Files(pkgs, cfg.MaxCycles)
+	default:
+		cycles, limitReached = cycle.Packages(pkgs, cfg.MaxCycles)
+	}
+
+	paths := make([]string, 0, len(cycles))
+	for _, c := range cycles {
+		suffix := testOnlySuffix(c.TestOnly())
+		if cfg.Edges == config.ImportEdges {
+			suffix += importsOnlySuffix(c.DeclarationLevel())
+		}
+		paths = append(paths, strings.Join(c.Path(), " -> ")+suffix)
+	}
+	return paths, limitReached, nil
+}
+
+// MarshalCycleChanges summarizes the import cycles which appeared, marked +,
+// and disappeared, marked -, between the cycle paths previous and current,
+// see CyclePaths. With config.ImportEdges they are the import cycles the
+// compiler rejects at either resolution.
+func MarshalCycleChanges(cfg *config.Config, modulePath string, previous, current []string) ([]byte, error) {
+	var resolution string
+	switch cfg.Resolution {
+	case config.FileResolution:
+		resolution = "file"
+	case config.PackageResolution:
+		resolution = "package"
+	default:
+		return nil, fmt.Errorf("marshal cycle changes: invalid resolution: %d", cfg.Resolution)
+	}
+
+	var appeared, disappeared []string
+	for _, path := range current {
+		if !slices.Contains(previous, path) {
+			appeared = append(appeared, path)
+		}
+	}
+	for _, path := range previous {
+		if !slices.Contains(current, path) {
+			disappeared = append(disappeared, path)
+		}
+	}
+	slices.Sort(appeared)
+	slices.Sort(disappeared)
+
+	kind := "import cycle(s) at " + resolution + " resolution"
+	if cfg.Edges == config.ImportEdges {
+		kind = "import cycle(s) rejected by the compiler"
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(
+		fmt.Sprintf(
+			"%s: %d %s, %d appeared, %d disappeared\n",
+			modulePath,
+			len(current),
+			kind,
+			len(appeared),
+			len(disappeared),
+		),
+	)
+	for _, path := range appeared {
+		buf.WriteString("+ " + path + "\n")
+	}
+	for _, path := range disappeared {
+		buf.WriteString("- " + path + "\n")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,58 @@
+package report_test
+
+import (
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/report"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshalCycleChanges(t *testing.T) {
+	cfg := config.Default()
+	previous := []string{
+		"a/a.go -> b/b.go -> a/a.go",
+		"b/b.go -> c/c.go -> b/b.go",
+	}
+	current := []string{
+		"b/b.go -> c/c.go -> b/b.go",
+		"a/a_test.go -> d/d.go -> a/a_test.go (test only)",
+		"c/c.go -> d/d.go -> c/c.go",
+	}
+
+	expected := `example.com/m: 3 import cycle(s) at file resolution, 2 appeared, 1 disappeared
++ a/a_test.go -> d/d.go -> a/a_test.go (test only)
++ c/c.go -> d/d.go -> c/c.go
+- a/a.go -> b/b.go -> a/a.go
+`
+	actual, err := report.MarshalCycleChanges(cfg, "example.com/m", previous, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(expected, string(actual)) {
+		t.Error(cmp.Diff(expected, string(actual)))
+	}
+
+	cfg.Resolution = config.PackageResolution
+	expected = "example.com/m: 0 import cycle(s) at package resolution, 0 appeared, 0 disappeared\n"
+	actual, err = report.MarshalCycleChanges(cfg, "example.com/m", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(expected, string(actual)) {
+		t.Error(cmp.Diff(expected, string(actual)))
+	}
+
+	cfg.Edges = config.ImportEdges
+	expected = `example.com/m: 1 import cycle(s) rejected by the compiler, 1 appeared, 0 disappeared
++ a -> c -> a (imports only)
+`
+	actual, err = report.MarshalCycleChanges(cfg, "example.com/m", nil, []string{"a -> c -> a (imports only)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(expected, string(actual)) {
+		t.Error(cmp.Diff(expected, string(actual)))
+	}
+}
@@ -0,0 +1,177 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/cycle"
+)
+
+// MarshalCycles returns the import cycle report, see WriteCycles.
+func MarshalCycles(cfg *config.Config, modulePath string, pkgs []*internal.Package) ([]byte, error) {
+	out := &bytes.Buffer{}
+	if err := WriteCycles(out, cfg, modulePath, pkgs); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// WriteCycles writes the import cycle report to w as it is generated. With
+// config.ImportEdges the report lists the import cycles the compiler rejects,
+// followed at file resolution by the declaration-level couplings between
+// files.
+func WriteCycles(w io.Writer, cfg *config.Config, modulePath string, pkgs []*internal.Package) error {
+	var resolution string
+	switch cfg.Resolution {
+	case config.FileResolution:
+		resolution = "file"
+	case config.PackageResolution:
+		resolution = "package"
+	default:
+		return fmt.Errorf("write cycles: invalid resolution: %d", cfg.Resolution)
+	}
+
+	buf := bufio.NewWriter(w)
+	if cfg.Edges == config.ImportEdges {
+		importCycles, importLimitReached := cycle.Imports(pkgs, cfg.MaxCycles)
+		writeCycles(
+			buf,
+			fmt.Sprintf("%s: %d import cycle(s) rejected by the compiler", modulePath, len(importCycles)),
+			importCycles,
+			importLimitReached,
+		)
+		// declaration-level couplings only differ from the import cycles
+		// between files
+		if cfg.Resolution != config.FileResolution {
+			return buf.Flush()
+		}
+		buf.WriteString("\n")
+	}
+
+	var cycles []*cycle.Cycle
+	var limitReached bool
+	kind := "import cycle(s)"
+	switch cfg.Resolution {
+	case config.FileResolution:
+		cycles, limitReached = cycle.Files(pkgs, cfg.MaxCycles)
+		if cfg.Edges == config.ImportEdges {
+			kind = "declaration-level coupling(s)"
+		}
+	case config.PackageResolution:
+		cycles, limitReached = cycle.Packages(pkgs, cfg.MaxCycles)
+	}
+	writeCycles(
+		buf,
+		fmt.Sprintf("%s: %d %s at %s resolution", modulePath, len(cycles), kind, resolution),
+		cycles,
+		limitReached,
+	)
+	return buf.Flush()
+}
+
+func writeCycles(
+	buf *bufio.Writer,
+	header string,
+	cycles []*cycle.Cycle,
+	limitReached bool,
+) {
+	buf.WriteString(header + "\n")
+	if limitReached {
+		buf.WriteString(
+			fmt.Sprintf(
+				"stopped after %d import cycle(s), more may exist\n",
+				len(cycles),
+			),
+		)
+	}
+
+	for i, c := range cycles {
+		buf.WriteString(
+			fmt.Sprintf(
+				"\n%d%s%s: %s\n",
+				i+1,
+				testOnlySuffix(c.TestOnly()),
+				importsOnlySuffix(c.DeclarationLevel()),
+				strings.Join(c.Path(), " -> "),
+			),
+		)
+		for _, hop := range c.Hops {
+			buf.WriteString(
+				fmt.Sprintf(
+					"\t%s -> %s%s: %s\n",
+					nodePath(hop.From),
+					nodePath(hop.To),
+					testOnlySuffix(hop.TestOnly),
+					hopDescription(hop),
+				),
+			)
+			for _, prov := range hop.Provenance {
+				buf.WriteString("\t\t" + prov.String() + "\n")
+			}
+		}
+	}
+}
+
+// nodePath is the module relative path of node, marking nodes of packages
+// outside the analyzed package patterns.
+func nodePath(node cycle.Node) string {
+	var pkg *internal.Package
+	switch node := node.(type) {
+	case *internal.Package:
+		pkg = node
+	case *internal.File:
+		pkg = node.Package
+	}
+	if pkg != nil && pkg.OutOfScope {
+		return node.ModuleRelativePath() + " (out of scope)"
+	}
+	return node.ModuleRelativePath()
+}
+
+func hopDescription(hop *cycle.Hop) string {
+	if len(hop.Decls) > 0 {
+		return strings.Join(declNames(hop.Decls), ", ") + linknameSuffix(hop.Linkname)
+	}
+	if hop.Blank {
+		return "blank import"
+	}
+	return "no referenced declarations"
+}
+
+func importsOnlySuffix(declarationLevel bool) string {
+	if declarationLevel {
+		return ""
+	}
+	return " (imports only)"
+}
+
+func linknameSuffix(linkname bool) string {
+	if !linkname {
+		return ""
+	}
+	return " (go:linkname)"
+}
+
+func testOnlySuffix(testOnly bool) string {
+	if !testOnly {
+		return ""
+	}
+	return " (test only)"
+}
+
+func declNames(decls []*internal.Decl) []string {
+	names := make([]string, 0, len(decls))
+	for _, decl := range decls {
+		if decl.File == nil || decl.File.Package == nil {
+			names = append(names, decl.QualifiedName())
+			continue
+		}
+		names = append(names, decl.File.Package.Name+"."+decl.QualifiedName())
+	}
+	return names
+}
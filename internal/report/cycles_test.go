@@ -0,0 +1,1021 @@
+package report_test
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/report"
+
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshalCycles(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// interlinked: a -> b -> a, b -> c -> b, a -> b -> c -> a
+			{
+				"interlinked",
+				[]*Node{
+					{
+						"main.go",
+						nil,
+						"main",
+						`
+package main
+
+import "example.com/interlinked/a"
+
+func main() {
+	a.AFn()
+}
+`,
+					},
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+import "example.com/interlinked/b"
+
+func AFn() {
+	b.BFn()
+	b.BFn2()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/interlinked/a"
+import "example.com/interlinked/c"
+
+func BFn() {
+	a.AFn()
+	c.CFn()
+}
+`,
+							},
+							{
+								"b2.go",
+								nil,
+								"b",
+								`
+package b
+
+func BFn2() {}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"c",
+						[]*Node{
+							{
+								"c.go",
+								nil,
+								"c",
+								`
+package c
+
+import "example.com/interlinked/a"
+import "example.com/interlinked/b"
+
+func CFn() {
+	a.AFn()
+	b.BFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	expectedByResolution := map[config.Resolution]string{
+		config.FileResolution: `example.com/interlinked: 3 import cycle(s) at file resolution
+
+1: a/a.go -> b/b.go -> a/a.go
+	a/a.go -> b/b.go: b.BFn
+		a/a.go:4:8 imports b, used at a/a.go:7:4 (b.BFn)
+	b/b.go -> a/a.go: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:8:4 (a.AFn)
+
+2: b/b.go -> c/c.go -> b/b.go
+	b/b.go -> c/c.go: c.CFn
+		b/b.go:5:8 imports c, used at b/b.go:9:4 (c.CFn)
+	c/c.go -> b/b.go: b.BFn
+		c/c.go:5:8 imports b, used at c/c.go:9:4 (b.BFn)
+
+3: a/a.go -> b/b.go -> c/c.go -> a/a.go
+	a/a.go -> b/b.go: b.BFn
+		a/a.go:4:8 imports b, used at a/a.go:7:4 (b.BFn)
+	b/b.go -> c/c.go: c.CFn
+		b/b.go:5:8 imports c, used at b/b.go:9:4 (c.CFn)
+	c/c.go -> a/a.go: a.AFn
+		c/c.go:4:8 imports a, used at c/c.go:8:4 (a.AFn)
+`,
+		config.PackageResolution: `example.com/interlinked: 3 import cycle(s) at package resolution
+
+1: a -> b -> a
+	a -> b: b.BFn, b.BFn2
+		a/a.go:4:8 imports b, used at a/a.go:7:4 (b.BFn), a/a.go:8:4 (b.BFn2)
+	b -> a: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:8:4 (a.AFn)
+
+2: b -> c -> b
+	b -> c: c.CFn
+		b/b.go:5:8 imports c, used at b/b.go:9:4 (c.CFn)
+	c -> b: b.BFn
+		c/c.go:5:8 imports b, used at c/c.go:9:4 (b.BFn)
+
+3: a -> b -> c -> a
+	a -> b: b.BFn, b.BFn2
+		a/a.go:4:8 imports b, used at a/a.go:7:4 (b.BFn), a/a.go:8:4 (b.BFn2)
+	b -> c: c.CFn
+		b/b.go:5:8 imports c, used at b/b.go:9:4 (c.CFn)
+	c -> a: a.AFn
+		c/c.go:4:8 imports a, used at c/c.go:8:4 (a.AFn)
+`,
+	}
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		pkgs := buildPackages(t, tmpDir, treeNode, nil, config.DeclarationEdges)
+
+		for resolution, expected := range expectedByResolution {
+			cfg := config.Default()
+			cfg.Resolution = resolution
+			actual, err := report.MarshalCycles(cfg, "example.com/"+testCase, pkgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(expected, string(actual)) {
+				t.Error(cmp.Diff(expected, string(actual)))
+			}
+		}
+
+		cfg := config.Default()
+		cfg.Resolution = config.PackageResolution
+		cfg.MaxCycles = 1
+		expected := `example.com/interlinked: 1 import cycle(s) at package resolution
+stopped after 1 import cycle(s), more may exist
+
+1: a -> b -> a
+	a -> b: b.BFn, b.BFn2
+		a/a.go:4:8 imports b, used at a/a.go:7:4 (b.BFn), a/a.go:8:4 (b.BFn2)
+	b -> a: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:8:4 (a.AFn)
+`
+		actual, err := report.MarshalCycles(cfg, "example.com/"+testCase, pkgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(expected, string(actual)) {
+			t.Error(cmp.Diff(expected, string(actual)))
+		}
+	}
+}
+
+func TestMarshalCycles_TestOnly(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// tests: a -> b -> a through a_test.go, b -> c -> b in production
+			{
+				"tests",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+func AFn() {}
+`,
+							},
+							{
+								"a_test.go",
+								nil,
+								"a",
+								`
+package a
+
+import "example.com/tests/b"
+
+func TestAFn() {
+	b.BFn()
+}
+`,
+							},
+							{
+								"external_test.go",
+								nil,
+								"a_test",
+								`
+package a_test
+
+import "example.com/tests/a"
+import "example.com/tests/b"
+
+func TestExternal() {
+	a.AFn()
+	b.BFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/tests/a"
+import "example.com/tests/c"
+
+func BFn() {
+	a.AFn()
+	c.CFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"c",
+						[]*Node{
+							{
+								"c.go",
+								nil,
+								"c",
+								`
+package c
+
+import "example.com/tests/b"
+
+func CFn() {
+	b.BFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	expected := `example.com/tests: 2 import cycle(s) at package resolution
+
+1 (test only): a -> b -> a
+	a -> b (test only): b.BFn
+		a/a_test.go:4:8 imports b, used at a/a_test.go:7:4 (b.BFn)
+	b -> a: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:8:4 (a.AFn)
+
+2: b -> c -> b
+	b -> c: c.CFn
+		b/b.go:5:8 imports c, used at b/b.go:9:4 (c.CFn)
+	c -> b: b.BFn
+		c/c.go:4:8 imports b, used at c/c.go:7:4 (b.BFn)
+`
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		pkgs := buildPackages(t, tmpDir, treeNode, nil, config.DeclarationEdges)
+
+		cfg := config.Default()
+		cfg.Resolution = config.PackageResolution
+		actual, err := report.MarshalCycles(cfg, "example.com/"+testCase, pkgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(expected, string(actual)) {
+			t.Error(cmp.Diff(expected, string(actual)))
+		}
+	}
+}
+
+func TestMarshalCycles_DotImports(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// dots: a -> b -> a through a dot-import, the field Name is not b.Name
+			{
+				"dots",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+import . "example.com/dots/b"
+
+type S struct {
+	Name string
+}
+
+func AFn() {
+	s := S{Name: "a"}
+	BFn(s.Name)
+	_ = BConst
+}
+`,
+							},
+							{
+								"other.go",
+								nil,
+								"a",
+								`
+package a
+
+func Other() {}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/dots/a"
+
+const BConst = 1
+
+func BFn(string) {
+	a.AFn()
+}
+`,
+							},
+							{
+								"name.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/dots/a"
+
+func Name() {
+	a.Other()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	expected := `example.com/dots: 1 import cycle(s) at file resolution
+
+1: a/a.go -> b/b.go -> a/a.go
+	a/a.go -> b/b.go: b.BConst, b.BFn
+		a/a.go:4:8 imports b, used at a/a.go:12:2 (b.BFn), a/a.go:13:6 (b.BConst)
+	b/b.go -> a/a.go: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:9:4 (a.AFn)
+`
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		pkgs := buildPackages(t, tmpDir, treeNode, nil, config.DeclarationEdges)
+
+		cfg := config.Default()
+		actual, err := report.MarshalCycles(cfg, "example.com/"+testCase, pkgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(expected, string(actual)) {
+			t.Error(cmp.Diff(expected, string(actual)))
+		}
+	}
+}
+
+func TestMarshalCycles_CgoAndLinkname(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// link: a -> b -> a through a //go:linkname directive, C is not a package
+			{
+				"link",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+import "example.com/link/b"
+
+func AFn() {
+	b.BFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+// #include <stdlib.h>
+import "C"
+
+import _ "unsafe"
+
+//go:linkname aFn example.com/link/a.AFn
+func aFn()
+
+//go:linkname BFn
+func BFn() {
+	C.free(nil)
+	aFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	expected := `example.com/link: 1 import cycle(s) at file resolution
+
+1: a/a.go -> b/b.go -> a/a.go
+	a/a.go -> b/b.go: b.BFn
+		a/a.go:4:8 imports b, used at a/a.go:7:4 (b.BFn)
+	b/b.go -> a/a.go: a.AFn (go:linkname)
+		b/b.go:9:1 links a (a.AFn)
+`
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		pkgs := buildPackages(t, tmpDir, treeNode, nil, config.DeclarationEdges)
+
+		for _, pkg := range pkgs {
+			if pkg.Name == "C" {
+				t.Errorf("%s: unexpected package C", testCase)
+			}
+		}
+
+		cfg := config.Default()
+		actual, err := report.MarshalCycles(cfg, "example.com/"+testCase, pkgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(expected, string(actual)) {
+			t.Error(cmp.Diff(expected, string(actual)))
+		}
+	}
+}
+
+func TestMarshalCycles_ImportEdges(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// imports: a -> b -> a through declarations, a -> c -> a through a
+			// blank import, and a -> b -> a between packages but not files
+			{
+				"imports",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+import (
+	"example.com/imports/b"
+	"example.com/imports/c"
+)
+
+func AFn() {
+	b.BFn()
+	c.CFn()
+}
+`,
+							},
+							{
+								"other.go",
+								nil,
+								"a",
+								`
+package a
+
+import "example.com/imports/b"
+
+func Other() {
+	b.Other()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/imports/a"
+
+func BFn() {
+	a.AFn()
+}
+`,
+							},
+							{
+								"other.go",
+								nil,
+								"b",
+								`
+package b
+
+func Other() {}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"c",
+						[]*Node{
+							{
+								"c.go",
+								nil,
+								"c",
+								`
+package c
+
+func CFn() {}
+`,
+							},
+							{
+								"init.go",
+								nil,
+								"c",
+								`
+package c
+
+import _ "example.com/imports/a"
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	expectedByResolution := map[config.Resolution]string{
+		config.FileResolution: `example.com/imports: 2 import cycle(s) rejected by the compiler
+
+1: a -> b -> a
+	a -> b: b.BFn, b.Other
+		a/a.go:5:2 imports b, used at a/a.go:10:4 (b.BFn)
+		a/other.go:4:8 imports b, used at a/other.go:7:4 (b.Other)
+	b -> a: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:7:4 (a.AFn)
+
+2 (imports only): a -> c -> a
+	a -> c: c.CFn
+		a/a.go:6:2 imports c, used at a/a.go:11:4 (c.CFn)
+	c -> a: blank import
+		c/init.go:4:8 imports a
+
+example.com/imports: 1 declaration-level coupling(s) at file resolution
+
+1: a/a.go -> b/b.go -> a/a.go
+	a/a.go -> b/b.go: b.BFn
+		a/a.go:5:2 imports b, used at a/a.go:10:4 (b.BFn)
+	b/b.go -> a/a.go: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:7:4 (a.AFn)
+`,
+		config.PackageResolution: `example.com/imports: 2 import cycle(s) rejected by the compiler
+
+1: a -> b -> a
+	a -> b: b.BFn, b.Other
+		a/a.go:5:2 imports b, used at a/a.go:10:4 (b.BFn)
+		a/other.go:4:8 imports b, used at a/other.go:7:4 (b.Other)
+	b -> a: a.AFn
+		b/b.go:4:8 imports a, used at b/b.go:7:4 (a.AFn)
+
+2 (imports only): a -> c -> a
+	a -> c: c.CFn
+		a/a.go:6:2 imports c, used at a/a.go:11:4 (c.CFn)
+	c -> a: blank import
+		c/init.go:4:8 imports a
+`,
+	}
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		pkgs := buildPackages(t, tmpDir, treeNode, nil, config.ImportEdges)
+
+		for resolution, expected := range expectedByResolution {
+			cfg := config.Default()
+			cfg.Resolution = resolution
+			cfg.Edges = config.ImportEdges
+			actual, err := report.MarshalCycles(cfg, "example.com/"+testCase, pkgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(expected, string(actual)) {
+				t.Error(cmp.Diff(expected, string(actual)))
+			}
+
+			expectedPaths := []string{"a -> b -> a", "a -> c -> a (imports only)"}
+			actualPaths, _, err := report.CyclePaths(cfg, pkgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(expectedPaths, actualPaths) {
+				t.Error(cmp.Diff(expectedPaths, actualPaths))
+			}
+		}
+	}
+}
+
+// buildPackages parses every directory of the tree, marks up import cycles
+// with the given edges, and returns the resulting packages. A nil filter
+// parses every file.
+func buildPackages(
+	t *testing.T,
+	tmpDir string,
+	treeNode *Node,
+	filter func(dirPath string, fi fs.FileInfo) bool,
+	edges config.Edges,
+) []*internal.Package {
+	testCase := treeNode.name
+	dirOut := make(chan string)
+	depVis, nodeOut := internalAST.NewDependencyVisitor()
+	builder := internalAST.NewPrimitiveBuilder(
+		"example.com/"+testCase,
+		tmpDir+string(os.PathSeparator)+"testdata"+string(os.PathSeparator)+testCase,
+	)
+	builder.SetEdges(edges)
+
+	directoryPathsInOrder := []string{}
+	walkTree(
+		treeNode,
+		treeNode.name,
+		func(path string, n *Node) {
+			if n.entries == nil {
+				return
+			}
+			directoryPathsInOrder = append(
+				directoryPathsInOrder,
+				tmpDir+string(os.PathSeparator)+"testdata"+string(os.PathSeparator)+path,
+			)
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for _, dirPath := range directoryPathsInOrder {
+			dirOut <- dirPath
+		}
+		close(dirOut)
+	}()
+
+	go func() {
+		for {
+			select {
+			case dirPath, ok := <-dirOut:
+				if !ok {
+					depVis.Close()
+					return
+				}
+				var dirFilter func(fi fs.FileInfo) bool
+				if filter != nil {
+					dirFilter = func(fi fs.FileInfo) bool {
+						return filter(dirPath, fi)
+					}
+				}
+				fset := token.NewFileSet()
+				pkgs, err := parser.ParseDir(fset, dirPath, dirFilter, parser.ParseComments)
+				if err != nil {
+					cancel()
+					t.Errorf("%s: %s", testCase, err)
+					return
+				}
+
+				depVis.SetFileSet(fset)
+				for _, pkg := range pkgs {
+					ast.Walk(depVis, pkg)
+				}
+
+			case <-ctx.Done():
+				depVis.Close()
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case astNode, ok := <-nodeOut:
+				if !ok {
+					cancel()
+					return
+				}
+				err := builder.AddNode(astNode)
+				if err != nil {
+					cancel()
+					t.Error(err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	<-ctx.Done()
+
+	err := builder.MarkupImportCycles()
+	if err != nil {
+		t.Fatalf(
+			"%s: error marking up import cycles: %s",
+			testCase,
+			err,
+		)
+	}
+	return builder.Packages()
+}
+
+// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L449
+type Node struct {
+	name    string
+	entries []*Node // nil if the entry is a file
+	pkg     string  // file package belongs to, empty if entry is a directory
+	data    string  // file content, empty if entry is a directory
+}
+
+// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L481
+func walkTree(n *Node, path string, f func(path string, n *Node)) {
+	f(path, n)
+	for _, e := range n.entries {
+		walkTree(e, filepath.Join(path, e.name), f)
+	}
+}
+
+// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L488
+func makeTree(t *testing.T, tree *Node) map[string]struct{} {
+	directories := make(map[string]struct{})
+	walkTree(tree, tree.name, func(path string, n *Node) {
+		if n.entries == nil {
+			fd, err := os.Create(path)
+			if err != nil {
+				t.Errorf("makeTree: %v", err)
+				return
+			}
+			if n.data != "" {
+				_, err = fd.Write([]byte(n.data))
+				if err != nil {
+					t.Errorf("makeTree: %v", err)
+					return
+				}
+			}
+			fd.Close()
+		} else {
+			os.Mkdir(path, 0770)
+			directories[path] = struct{}{}
+		}
+	})
+	return directories
+}
+
+// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L553
+func chtmpdir(t *testing.T) (restore func()) {
+	oldwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("chtmpdir: %v", err)
+	}
+	d, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("chtmpdir: %v", err)
+	}
+	if err := os.Chdir(d); err != nil {
+		t.Fatalf("chtmpdir: %v", err)
+	}
+	return func() {
+		if err := os.Chdir(oldwd); err != nil {
+			t.Fatalf("chtmpdir: %v", err)
+		}
+		os.RemoveAll(d)
+	}
+}
@@ -0,0 +1,29 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/samlitowitz/goimportcycle/internal/analyzer"
+)
+
+// MarshalDiagnostics writes the diagnostics section of the import cycle
+// report, listing the directories, files, and declarations skipped with
+// config.KeepGoing, nothing when none were.
+func MarshalDiagnostics(modulePath, moduleRootDir string, diagnostics []*analyzer.Diagnostic) []byte {
+	if len(diagnostics) == 0 {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(
+		fmt.Sprintf(
+			"%s: %d diagnostic(s), skipped from the analysis\n\n",
+			modulePath,
+			len(diagnostics),
+		),
+	)
+	for _, diagnostic := range diagnostics {
+		buf.WriteString(diagnostic.ModuleRelativeString(moduleRootDir) + "\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,53 @@
+package report_test
+
+import (
+	"errors"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/analyzer"
+	"github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/report"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshalDiagnostics(t *testing.T) {
+	moduleRootDir := filepath.FromSlash("/tmp/m")
+	badFile := token.Position{Filename: filepath.Join(moduleRootDir, "a", "bad.go"), Line: 3, Column: 12}
+	badDecl := token.Position{Filename: filepath.Join(moduleRootDir, "b", "b.go"), Line: 5, Column: 1}
+	badDir := token.Position{Filename: filepath.Join(moduleRootDir, "c")}
+	diagnostics := []*analyzer.Diagnostic{
+		{
+			Pos:     badFile,
+			Skipped: "file",
+			Err:     &analyzer.ParseError{Pos: badFile, Err: errors.New("expected '}', found 'EOF'")},
+		},
+		{
+			Pos:     badDecl,
+			Skipped: "declaration",
+			Err:     &ast.NodeError{Pos: badDecl, Err: errors.New("add gen decl: duplicate declaration: T")},
+		},
+		{
+			Pos:     badDir,
+			Skipped: "directory",
+			Err:     &analyzer.ParseError{Pos: badDir, Err: errors.New("permission denied")},
+		},
+	}
+
+	expected := `example.com/m: 3 diagnostic(s), skipped from the analysis
+
+a/bad.go:3:12: skipped file: expected '}', found 'EOF'
+b/b.go:5:1: skipped declaration: add gen decl: duplicate declaration: T
+c: skipped directory: permission denied
+`
+	actual := report.MarshalDiagnostics("example.com/m", moduleRootDir, diagnostics)
+	if !cmp.Equal(expected, string(actual)) {
+		t.Error(cmp.Diff(expected, string(actual)))
+	}
+
+	if actual := report.MarshalDiagnostics("example.com/m", moduleRootDir, nil); len(actual) != 0 {
+		t.Errorf("expected no output without diagnostics, got %q", actual)
+	}
+}
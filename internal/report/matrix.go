@@ -0,0 +1,169 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/cycle"
+)
+
+// PlatformPackages are the packages resulting from analyzing a module for a
+// single platform.
+type PlatformPackages struct {
+	Platform string
+	Packages []*internal.Package
+}
+
+// MarshalMatrix reports which edges and import cycles exist on which of the
+// analyzed platforms.
+func MarshalMatrix(cfg *config.Config, modulePath string, results []*PlatformPackages) ([]byte, error) {
+	var resolution string
+	var edgesFn func(pkgs []*internal.Package) []string
+	var cyclesFn func(pkgs []*internal.Package, limit int) ([]*cycle.Cycle, bool)
+
+	switch cfg.Resolution {
+	case config.FileResolution:
+		resolution = "file"
+		edgesFn = fileEdges
+		cyclesFn = cycle.Files
+	case config.PackageResolution:
+		resolution = "package"
+		edgesFn = packageEdges
+		cyclesFn = cycle.Packages
+	default:
+		return nil, fmt.Errorf("marshal matrix: invalid resolution: %d", cfg.Resolution)
+	}
+
+	platforms := make([]string, 0, len(results))
+	edges := newPlatformSet()
+	cycles := newPlatformSet()
+	limitReached := make([]string, 0)
+	for _, result := range results {
+		platforms = append(platforms, result.Platform)
+		for _, edge := range edgesFn(result.Packages) {
+			edges.add(edge, result.Platform)
+		}
+		platformCycles, platformLimitReached := cyclesFn(result.Packages, cfg.MaxCycles)
+		for _, c := range platformCycles {
+			cycles.add(strings.Join(c.Path(), " -> ")+testOnlySuffix(c.TestOnly()), result.Platform)
+		}
+		if platformLimitReached {
+			limitReached = append(limitReached, result.Platform)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(
+		fmt.Sprintf(
+			"%s: import matrix at %s resolution\nplatforms: %s\n",
+			modulePath,
+			resolution,
+			strings.Join(platforms, ", "),
+		),
+	)
+	if len(limitReached) > 0 {
+		buf.WriteString(
+			fmt.Sprintf(
+				"stopped after %d import cycle(s), more may exist: %s\n",
+				cfg.MaxCycles,
+				strings.Join(limitReached, ", "),
+			),
+		)
+	}
+	buf.WriteString("\nedges:\n")
+	edges.write(buf, len(platforms))
+	buf.WriteString("\ncycles:\n")
+	cycles.write(buf, len(platforms))
+
+	return buf.Bytes(), nil
+}
+
+func packageEdges(pkgs []*internal.Package) []string {
+	edges := make([]string, 0)
+	for _, pkg := range pkgs {
+		if pkg.IsStub {
+			continue
+		}
+		for _, file := range pkg.Files {
+			if file.IsStub {
+				continue
+			}
+			for _, imp := range file.Imports {
+				if imp.Package == nil || imp.Package.IsStub {
+					continue
+				}
+				edges = append(
+					edges,
+					pkg.ModuleRelativePath()+" -> "+imp.Package.ModuleRelativePath(),
+				)
+			}
+		}
+	}
+	return edges
+}
+
+func fileEdges(pkgs []*internal.Package) []string {
+	edges := make([]string, 0)
+	for _, pkg := range pkgs {
+		if pkg.IsStub {
+			continue
+		}
+		for _, file := range pkg.Files {
+			if file.IsStub {
+				continue
+			}
+			for _, imp := range file.Imports {
+				if imp.Package == nil || imp.Package.IsStub {
+					continue
+				}
+				for _, refTyp := range imp.ReferencedTypes {
+					edges = append(
+						edges,
+						file.ModuleRelativePath()+" -> "+refTyp.File.ModuleRelativePath(),
+					)
+				}
+			}
+		}
+	}
+	return edges
+}
+
+type platformSet struct {
+	platformsByKey map[string][]string
+}
+
+func newPlatformSet() *platformSet {
+	return &platformSet{
+		platformsByKey: make(map[string][]string),
+	}
+}
+
+func (set *platformSet) add(key, platform string) {
+	if slices.Contains(set.platformsByKey[key], platform) {
+		return
+	}
+	set.platformsByKey[key] = append(set.platformsByKey[key], platform)
+}
+
+func (set *platformSet) write(buf *bytes.Buffer, platformCount int) {
+	if len(set.platformsByKey) == 0 {
+		buf.WriteString("\tnone\n")
+		return
+	}
+	keys := make([]string, 0, len(set.platformsByKey))
+	for key := range set.platformsByKey {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		platforms := "all"
+		if len(set.platformsByKey[key]) != platformCount {
+			platforms = strings.Join(set.platformsByKey[key], ", ")
+		}
+		buf.WriteString(fmt.Sprintf("\t%s: %s\n", key, platforms))
+	}
+}
@@ -0,0 +1,170 @@
+package report_test
+
+import (
+	"io/fs"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/platform"
+	"github.com/samlitowitz/goimportcycle/internal/report"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshalMatrix(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// platform: a -> b -> a on linux only
+			{
+				"platform",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+func AFn() {}
+`,
+							},
+							{
+								"a_linux.go",
+								nil,
+								"a",
+								`
+package a
+
+import "example.com/platform/b"
+
+func LinuxFn() {
+	b.BFn()
+}
+`,
+							},
+							{
+								"a_windows.go",
+								nil,
+								"a",
+								`
+package a
+
+func LinuxFn() {}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/platform/a"
+
+func BFn() {
+	a.AFn()
+}
+`,
+							},
+							{
+								"ignore.go",
+								nil,
+								"main",
+								`//go:build ignore
+
+package main
+
+func main() {}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	platforms, err := platform.ParseList("linux/amd64,windows/amd64", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+
+		results := make([]*report.PlatformPackages, 0, len(platforms))
+		for _, p := range platforms {
+			buildContext := p.Context()
+			filter := func(dirPath string, fi fs.FileInfo) bool {
+				match, err := buildContext.MatchFile(dirPath, fi.Name())
+				if err != nil {
+					t.Errorf("%s: %s", testCase, err)
+				}
+				return match
+			}
+			results = append(results, &report.PlatformPackages{
+				Platform: p.String(),
+				Packages: buildPackages(t, tmpDir, treeNode, filter, config.DeclarationEdges),
+			})
+		}
+
+		expected := `example.com/platform: import matrix at package resolution
+platforms: linux/amd64, windows/amd64
+
+edges:
+	a -> b: linux/amd64
+	b -> a: all
+
+cycles:
+	a -> b -> a: linux/amd64
+`
+		cfg := config.Default()
+		cfg.Resolution = config.PackageResolution
+		actual, err := report.MarshalMatrix(cfg, "example.com/"+testCase, results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(expected, string(actual)) {
+			t.Error(cmp.Diff(expected, string(actual)))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/samlitowitz/goimportcycle/internal"
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/cycle"
+)
+
+// MarshalSuggestions returns the cycle-breaking suggestions, see
+// WriteSuggestions.
+func MarshalSuggestions(cfg *config.Config, modulePath string, pkgs []*internal.Package) ([]byte, error) {
+	out := &bytes.Buffer{}
+	if err := WriteSuggestions(out, cfg, modulePath, pkgs); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// WriteSuggestions writes the hops, at the resolution of cfg, whose imports
+// to remove to break every import cycle to w, see cycle.SuggestPackages. The
+// hops breaking the most cycles per referenced declaration are listed first,
+// each followed by the imports it is made of. With config.ImportEdges the
+// hops break the import cycles the compiler rejects, followed at file
+// resolution by the hops breaking the declaration-level couplings between
+// files, as in the import cycle report.
+func WriteSuggestions(w io.Writer, cfg *config.Config, modulePath string, pkgs []*internal.Package) error {
+	var resolution string
+	switch cfg.Resolution {
+	case config.FileResolution:
+		resolution = "file"
+	case config.PackageResolution:
+		resolution = "package"
+	default:
+		return fmt.Errorf("write suggestions: invalid resolution: %d", cfg.Resolution)
+	}
+
+	buf := bufio.NewWriter(w)
+	if cfg.Edges == config.ImportEdges {
+		suggestions, cycles, limitReached := cycle.SuggestPackages(pkgs, config.ImportEdges, cfg.MaxCycles)
+		writeSuggestions(
+			buf,
+			fmt.Sprintf(
+				"%s: %d suggestion(s) breaking %d import cycle(s) rejected by the compiler",
+				modulePath,
+				len(suggestions),
+				cycles,
+			),
+			suggestions,
+			cycles,
+			limitReached,
+		)
+		// declaration-level couplings only differ from the import cycles
+		// between files
+		if cfg.Resolution != config.FileResolution {
+			return buf.Flush()
+		}
+		buf.WriteString("\n")
+	}
+
+	var suggestions []*cycle.Suggestion
+	var cycles int
+	var limitReached bool
+	kind := "import cycle(s)"
+	switch cfg.Resolution {
+	case config.FileResolution:
+		suggestions, cycles, limitReached = cycle.SuggestFiles(pkgs, cfg.MaxCycles)
+		if cfg.Edges == config.ImportEdges {
+			kind = "declaration-level coupling(s)"
+		}
+	case config.PackageResolution:
+		suggestions, cycles, limitReached = cycle.SuggestPackages(pkgs, config.DeclarationEdges, cfg.MaxCycles)
+	}
+	writeSuggestions(
+		buf,
+		fmt.Sprintf(
+			"%s: %d suggestion(s) breaking %d %s at %s resolution",
+			modulePath,
+			len(suggestions),
+			cycles,
+			kind,
+			resolution,
+		),
+		suggestions,
+		cycles,
+		limitReached,
+	)
+	return buf.Flush()
+}
+
+func writeSuggestions(
+	buf *bufio.Writer,
+	header string,
+	suggestions []*cycle.Suggestion,
+	cycles int,
+	limitReached bool,
+) {
+	buf.WriteString(header + "\n")
+	if limitReached {
+		buf.WriteString(
+			fmt.Sprintf(
+				"stopped after %d import cycle(s), more may exist which the suggestions do not account for\n",
+				cycles,
+			),
+		)
+	}
+
+	for i, suggestion := range suggestions {
+		hop := suggestion.Hop
+		buf.WriteString(
+			fmt.Sprintf(
+				"\n%d: %s -> %s%s: breaks %d import cycle(s), %d declaration(s): %s\n",
+				i+1,
+				nodePath(hop.From),
+				nodePath(hop.To),
+				testOnlySuffix(hop.TestOnly),
+				suggestion.Cycles,
+				len(hop.Decls),
+				hopDescription(hop),
+			),
+		)
+		for _, prov := range hop.Provenance {
+			buf.WriteString("\t" + prov.String() + "\n")
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package report_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/report"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshalSuggestions(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// interlinked: a -> b -> a, b -> c -> b, a -> b -> c -> a
+			{
+				"interlinked",
+				[]*Node{
+					{
+						"main.go",
+						nil,
+						"main",
+						`
+package main
+
+import "example.com/interlinked/a"
+
+func main() {
+	a.AFn()
+}
+`,
+					},
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+import "example.com/interlinked/b"
+
+func AFn() {
+	b.BFn()
+	b.BFn2()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"b",
+						[]*Node{
+							{
+								"b.go",
+								nil,
+								"b",
+								`
+package b
+
+import "example.com/interlinked/a"
+import "example.com/interlinked/c"
+
+func BFn() {
+	a.AFn()
+	c.CFn()
+}
+`,
+							},
+							{
+								"b2.go",
+								nil,
+								"b",
+								`
+package b
+
+func BFn2() {}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"c",
+						[]*Node{
+							{
+								"c.go",
+								nil,
+								"c",
+								`
+package c
+
+import "example.com/interlinked/a"
+import "example.com/interlinked/b"
+
+func CFn() {
+	a.AFn()
+	b.BFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	expectedByResolution := map[config.Resolution]string{
+		config.FileResolution: `example.com/interlinked: 2 suggestion(s) breaking 3 import cycle(s) at file resolution
+
+1: a/a.go -> b/b.go: breaks 2 import cycle(s), 1 declaration(s): b.BFn
+	a/a.go:4:8 imports b, used at a/a.go:7:4 (b.BFn)
+
+2: b/b.go -> c/c.go: breaks 2 import cycle(s), 1 declaration(s): c.CFn
+	b/b.go:5:8 imports c, used at b/b.go:9:4 (c.CFn)
+`,
+		// a -> b references two declarations, b -> a one
+		config.PackageResolution: `example.com/interlinked: 2 suggestion(s) breaking 3 import cycle(s) at package resolution
+
+1: b -> c: breaks 2 import cycle(s), 1 declaration(s): c.CFn
+	b/b.go:5:8 imports c, used at b/b.go:9:4 (c.CFn)
+
+2: b -> a: breaks 1 import cycle(s), 1 declaration(s): a.AFn
+	b/b.go:4:8 imports a, used at b/b.go:8:4 (a.AFn)
+`,
+	}
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		pkgs := buildPackages(t, tmpDir, treeNode, nil, config.DeclarationEdges)
+
+		for resolution, expected := range expectedByResolution {
+			cfg := config.Default()
+			cfg.Resolution = resolution
+			actual, err := report.MarshalSuggestions(cfg, "example.com/"+testCase, pkgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(expected, string(actual)) {
+				t.Error(cmp.Diff(expected, string(actual)))
+			}
+		}
+
+		cfg := config.Default()
+		cfg.Resolution = config.PackageResolution
+		cfg.MaxCycles = 1
+		expected := `example.com/interlinked: 1 suggestion(s) breaking 1 import cycle(s) at package resolution
+stopped after 1 import cycle(s), more may exist which the suggestions do not account for
+
+1: b -> a: breaks 1 import cycle(s), 1 declaration(s): a.AFn
+	b/b.go:4:8 imports a, used at b/b.go:8:4 (a.AFn)
+`
+		actual, err := report.MarshalSuggestions(cfg, "example.com/"+testCase, pkgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(expected, string(actual)) {
+			t.Error(cmp.Diff(expected, string(actual)))
+		}
+	}
+}
+
+func TestMarshalSuggestions_ImportEdges(t *testing.T) {
+	// REFURL: https://github.com/golang/go/blob/988b718f4130ab5b3ce5a5774e1a58e83c92a163/src/path/filepath/path_test.go#L600
+	// -- START -- //
+	if runtime.GOOS == "ios" {
+		restore := chtmpdir(t)
+		defer restore()
+	}
+
+	tmpDir := t.TempDir()
+
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal("finding working dir:", err)
+	}
+	if err = os.Chdir(tmpDir); err != nil {
+		t.Fatal("entering temp dir:", err)
+	}
+	defer os.Chdir(origDir)
+	// -- END -- //
+
+	tree := &Node{
+		"testdata",
+		[]*Node{
+			// blank: a -> c -> a through a blank import only
+			{
+				"blank",
+				[]*Node{
+					{
+						"a",
+						[]*Node{
+							{
+								"a.go",
+								nil,
+								"a",
+								`
+package a
+
+import "example.com/blank/c"
+
+func AFn() {
+	c.CFn()
+}
+`,
+							},
+						},
+						"",
+						"",
+					},
+					{
+						"c",
+						[]*Node{
+							{
+								"c.go",
+								nil,
+								"c",
+								`
+package c
+
+func CFn() {}
+`,
+							},
+							{
+								"init.go",
+								nil,
+								"c",
+								`
+package c
+
+import _ "example.com/blank/a"
+`,
+							},
+						},
+						"",
+						"",
+					},
+				},
+				"",
+				"",
+			},
+		},
+		"",
+		"",
+	}
+	makeTree(t, tree)
+
+	expectedByResolution := map[config.Resolution]string{
+		config.FileResolution: `example.com/blank: 1 suggestion(s) breaking 1 import cycle(s) rejected by the compiler
+
+1: a -> c: breaks 1 import cycle(s), 1 declaration(s): c.CFn
+	a/a.go:4:8 imports c, used at a/a.go:7:4 (c.CFn)
+
+example.com/blank: 0 suggestion(s) breaking 0 declaration-level coupling(s) at file resolution
+`,
+		config.PackageResolution: `example.com/blank: 1 suggestion(s) breaking 1 import cycle(s) rejected by the compiler
+
+1: a -> c: breaks 1 import cycle(s), 1 declaration(s): c.CFn
+	a/a.go:4:8 imports c, used at a/a.go:7:4 (c.CFn)
+`,
+	}
+
+	for _, treeNode := range tree.entries {
+		testCase := treeNode.name
+		pkgs := buildPackages(t, tmpDir, treeNode, nil, config.ImportEdges)
+
+		for resolution, expected := range expectedByResolution {
+			cfg := config.Default()
+			cfg.Resolution = resolution
+			cfg.Edges = config.ImportEdges
+			actual, err := report.MarshalSuggestions(cfg, "example.com/"+testCase, pkgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(expected, string(actual)) {
+				t.Error(cmp.Diff(expected, string(actual)))
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package typecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+)
+
+// sourceImporter type checks the packages outside the module from source,
+// selecting their files with the build context of the analyzed platform.
+// Cgo files are checked as they are, references to C are faked.
+type sourceImporter struct {
+	buildContext *build.Context
+	fset         *token.FileSet
+
+	// packages are the checked packages by import path, nil while a package
+	// is being checked
+	packages map[string]*types.Package
+}
+
+func newSourceImporter(fset *token.FileSet, moduleRootDir string, buildContext *build.Context) *sourceImporter {
+	// the go command resolves the dependencies of the module from its root
+	moduleContext := *buildContext
+	moduleContext.Dir = moduleRootDir
+	return &sourceImporter{
+		buildContext: &moduleContext,
+		fset:         fset,
+		packages:     make(map[string]*types.Package),
+	}
+}
+
+func (imp *sourceImporter) Import(path string) (*types.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+func (imp *sourceImporter) ImportFrom(path, dir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	bp, err := imp.buildContext.Import(path, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pkg, ok := imp.packages[bp.ImportPath]; ok {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through %s", bp.ImportPath)
+		}
+		return pkg, nil
+	}
+
+	names := append(bp.GoFiles, bp.CgoFiles...)
+	files := make([]*ast.File, 0, len(names))
+	for _, name := range names {
+		file, err := parser.ParseFile(imp.fset, filepath.Join(bp.Dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	imp.packages[bp.ImportPath] = nil
+	conf := &types.Config{
+		Importer:         imp,
+		FakeImportC:      true,
+		IgnoreFuncBodies: true,
+		// the declarations are needed, not the errors of a dependency
+		Error: func(error) {},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, imp.fset, files, nil)
+	imp.packages[bp.ImportPath] = pkg
+	return pkg, nil
+}
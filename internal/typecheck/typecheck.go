@@ -0,0 +1,394 @@
+package typecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+)
+
+// Result holds the references to declarations in other packages found by
+// type checking a module.
+type Result struct {
+	// References are ordered by the position of their use.
+	References []*internalAST.Reference
+	// Errors are the type errors reported while checking, references which
+	// could not be resolved are missing from References.
+	Errors []error
+
+	resolved map[*ast.Ident]struct{}
+}
+
+// Resolved reports whether the use of ident produced a reference.
+func (r *Result) Resolved(ident *ast.Ident) bool {
+	_, ok := r.resolved[ident]
+	return ok
+}
+
+// Check type checks every package in pkgsByDir, keyed by absolute directory
+// and package name as returned by parser.ParseDir, and resolves every use of
+// a declaration in another package to the file declaring it.
+//
+// Packages in the module are checked from the parsed files, all other
+// packages are checked from source, their files selected by buildContext as
+// the parsed files are. A package importing a package through
+// an import cycle is checked again once the imported package is complete.
+//
+// Only uses of declarations in packages imported by the using file are
+// resolved, e.g. a method promoted from a package the file does not import
+// is skipped.
+func Check(
+	fset *token.FileSet,
+	modulePath, moduleRootDir string,
+	pkgsByDir map[string]map[string]*ast.Package,
+	buildContext *build.Context,
+) *Result {
+	result := &Result{
+		resolved: make(map[*ast.Ident]struct{}),
+	}
+	imp := &moduleImporter{
+		fset:     fset,
+		fallback: newSourceImporter(fset, moduleRootDir, buildContext),
+		files:    make(map[string][]*ast.File),
+		names:    make(map[string]string),
+		checked:  make(map[string]*types.Package),
+		infos:    make(map[string]*types.Info),
+		errs:     make(map[string][]error),
+
+		incomplete: make(map[string]struct{}),
+	}
+	decls := newDeclIndex(fset)
+
+	dirs := make([]string, 0, len(pkgsByDir))
+	for dir := range pkgsByDir {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+
+	type checkUnit struct {
+		importPath string
+		name       string
+		files      []*ast.File
+		// variant is set when files differ from the package as imported
+		variant bool
+	}
+	var units []checkUnit
+	for _, dir := range dirs {
+		importPath := packageImportPath(modulePath, moduleRootDir, dir)
+		names := make([]string, 0, len(pkgsByDir[dir]))
+		for name := range pkgsByDir[dir] {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			files := sortedFiles(pkgsByDir[dir][name])
+			decls.add(files)
+
+			if strings.HasSuffix(name, "_test") && onlyTestFiles(fset, files) {
+				units = append(units, checkUnit{importPath + "_test", name, files, true})
+				continue
+			}
+			var productionFiles []*ast.File
+			for _, file := range files {
+				if !isTestFile(fset, file) {
+					productionFiles = append(productionFiles, file)
+				}
+			}
+			if len(productionFiles) > 0 {
+				imp.files[importPath] = productionFiles
+				imp.names[importPath] = name
+			}
+			units = append(units, checkUnit{importPath, name, files, len(productionFiles) != len(files)})
+		}
+	}
+
+	// check every package as imported, then check again the packages which
+	// imported a package through an import cycle, every import is complete
+	// by then
+	paths := make([]string, 0, len(imp.files))
+	for path := range imp.files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		imp.check(path)
+	}
+	for _, path := range paths {
+		if _, ok := imp.incomplete[path]; !ok {
+			continue
+		}
+		delete(imp.checked, path)
+		imp.check(path)
+	}
+
+	for _, unit := range units {
+		if !unit.variant {
+			pkg, info := imp.check(unit.importPath)
+			result.Errors = append(result.Errors, imp.errs[unit.importPath]...)
+			result.addReferences(fset, decls, pkg, unit.files, info)
+			continue
+		}
+		// a package with its in-package tests or an external test package
+		pkg := types.NewPackage(unit.importPath, unit.name)
+		info := &types.Info{
+			Uses: make(map[*ast.Ident]types.Object),
+		}
+		conf := &types.Config{
+			Importer:    imp,
+			FakeImportC: true,
+			Error: func(err error) {
+				result.Errors = append(result.Errors, err)
+			},
+		}
+		_ = types.NewChecker(conf, fset, pkg, info).Files(unit.files)
+		result.addReferences(fset, decls, pkg, unit.files, info)
+	}
+	return result
+}
+
+func (r *Result) addReferences(
+	fset *token.FileSet,
+	decls *declIndex,
+	pkg *types.Package,
+	files []*ast.File,
+	info *types.Info,
+) {
+	uses := make([]*ast.Ident, 0, len(info.Uses))
+	for ident := range info.Uses {
+		uses = append(uses, ident)
+	}
+	slices.SortFunc(uses, func(a, b *ast.Ident) int {
+		return int(a.Pos() - b.Pos())
+	})
+
+	for _, file := range files {
+		absPath := fset.File(file.Pos()).Name()
+		imports := make(map[string]struct{}, len(file.Imports))
+		for _, spec := range file.Imports {
+			imports[strings.Trim(spec.Path.Value, "\"`")] = struct{}{}
+		}
+
+		for _, ident := range uses {
+			if ident.Pos() < file.Pos() || ident.Pos() >= file.End() {
+				continue
+			}
+			obj := info.Uses[ident]
+			if obj.Pkg() == nil || obj.Pkg() == pkg {
+				continue
+			}
+			if _, ok := obj.(*types.PkgName); ok {
+				continue
+			}
+			if _, ok := imports[obj.Pkg().Path()]; !ok {
+				continue
+			}
+			declAbsPath := fset.Position(obj.Pos()).Filename
+			declName, ok := decls.name(declAbsPath, obj.Pos())
+			if !ok {
+				declName, ok = objectName(obj)
+			}
+			if !ok {
+				continue
+			}
+			r.References = append(r.References, &internalAST.Reference{
+				Ident:       ident,
+				AbsPath:     absPath,
+				ImportPath:  obj.Pkg().Path(),
+				DeclAbsPath: declAbsPath,
+				DeclName:    declName,
+				Position:    fset.Position(ident.Pos()),
+			})
+			r.resolved[ident] = struct{}{}
+		}
+	}
+}
+
+// objectName names a declaration outside the module the way a selector
+// expression would, fields are skipped as their type is referenced already.
+func objectName(obj types.Object) (string, bool) {
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.IsField() {
+			return "", false
+		}
+	case *types.Func:
+		sig, ok := obj.Type().(*types.Signature)
+		if !ok || sig.Recv() == nil {
+			break
+		}
+		recv := sig.Recv().Type()
+		if ptr, ok := recv.(*types.Pointer); ok {
+			recv = ptr.Elem()
+		}
+		named, ok := types.Unalias(recv).(*types.Named)
+		if !ok {
+			// method of an interface
+			return "", false
+		}
+		return named.Obj().Name() + "." + obj.Name(), true
+	}
+	return obj.Name(), true
+}
+
+type moduleImporter struct {
+	fset     *token.FileSet
+	fallback types.ImporterFrom
+
+	// files are the non-test files of each package in the module
+	files   map[string][]*ast.File
+	names   map[string]string
+	checked map[string]*types.Package
+	infos   map[string]*types.Info
+	errs    map[string][]error
+
+	// checking is the stack of packages being checked
+	checking []string
+	// incomplete are the packages which imported a package being checked
+	incomplete map[string]struct{}
+}
+
+func (imp *moduleImporter) Import(path string) (*types.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+func (imp *moduleImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if _, ok := imp.files[path]; !ok {
+		return imp.fallback.ImportFrom(path, dir, mode)
+	}
+	if slices.Contains(imp.checking, path) {
+		imp.incomplete[imp.checking[len(imp.checking)-1]] = struct{}{}
+		return nil, fmt.Errorf("import cycle through %s", path)
+	}
+	pkg, _ := imp.check(path)
+	return pkg, nil
+}
+
+// check type checks the package in the module at path once.
+func (imp *moduleImporter) check(path string) (*types.Package, *types.Info) {
+	if pkg, ok := imp.checked[path]; ok {
+		return pkg, imp.infos[path]
+	}
+	pkg := types.NewPackage(path, imp.names[path])
+	info := &types.Info{
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	var errs []error
+	conf := &types.Config{
+		Importer:    imp,
+		FakeImportC: true,
+		Error: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+
+	imp.checking = append(imp.checking, path)
+	delete(imp.incomplete, path)
+	_ = types.NewChecker(conf, imp.fset, pkg, info).Files(imp.files[path])
+	imp.checking = imp.checking[:len(imp.checking)-1]
+
+	imp.checked[path] = pkg
+	imp.infos[path] = info
+	imp.errs[path] = errs
+	return pkg, info
+}
+
+// declIndex finds the top-level declaration enclosing a position.
+type declIndex struct {
+	fset  *token.FileSet
+	files map[string]*ast.File
+}
+
+func newDeclIndex(fset *token.FileSet) *declIndex {
+	return &declIndex{
+		fset:  fset,
+		files: make(map[string]*ast.File),
+	}
+}
+
+func (idx *declIndex) add(files []*ast.File) {
+	for _, file := range files {
+		idx.files[idx.fset.File(file.Pos()).Name()] = file
+	}
+}
+
+// name returns the qualified name, as used by internal.Decl, of the
+// top-level declaration enclosing pos in the file at absPath.
+func (idx *declIndex) name(absPath string, pos token.Pos) (string, bool) {
+	file, ok := idx.files[absPath]
+	if !ok {
+		return "", false
+	}
+	for _, decl := range file.Decls {
+		if pos < decl.Pos() || pos >= decl.End() {
+			continue
+		}
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil || len(decl.Recv.List) == 0 {
+				return decl.Name.String(), true
+			}
+			return internalAST.ReceiverTypeName(decl.Recv.List[0].Type) + "." + decl.Name.String(), true
+
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if pos < spec.Pos() || pos >= spec.End() {
+					continue
+				}
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					return spec.Name.String(), true
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.Pos() == pos {
+							return name.String(), true
+						}
+					}
+					return spec.Names[0].String(), true
+				}
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
+
+func packageImportPath(modulePath, moduleRootDir, dir string) string {
+	rel, err := filepath.Rel(moduleRootDir, dir)
+	if err != nil || rel == "." {
+		return modulePath
+	}
+	return fmt.Sprintf("%s/%s", modulePath, filepath.ToSlash(rel))
+}
+
+func sortedFiles(pkg *ast.Package) []*ast.File {
+	filenames := make([]string, 0, len(pkg.Files))
+	for filename := range pkg.Files {
+		filenames = append(filenames, filename)
+	}
+	slices.Sort(filenames)
+	files := make([]*ast.File, 0, len(filenames))
+	for _, filename := range filenames {
+		files = append(files, pkg.Files[filename])
+	}
+	return files
+}
+
+func isTestFile(fset *token.FileSet, file *ast.File) bool {
+	return strings.HasSuffix(fset.File(file.Pos()).Name(), "_test.go")
+}
+
+func onlyTestFiles(fset *token.FileSet, files []*ast.File) bool {
+	for _, file := range files {
+		if !isTestFile(fset, file) {
+			return false
+		}
+	}
+	return true
+}
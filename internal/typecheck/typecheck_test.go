@@ -0,0 +1,276 @@
+package typecheck_test
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	internalAST "github.com/samlitowitz/goimportcycle/internal/ast"
+	"github.com/samlitowitz/goimportcycle/internal/typecheck"
+)
+
+func TestCheck(t *testing.T) {
+	moduleRootDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": `package a
+
+import (
+	"fmt"
+
+	"example.com/m/b"
+)
+
+type T struct{}
+
+func (*T) M() {}
+
+var _ b.I = (*T)(nil)
+
+func A() {
+	var v b.T
+	v.Method()
+	o := b.Outer{}
+	_ = o.Field
+	_ = b.Alias{}
+	fmt.Println(v)
+}
+`,
+		"a/a_test.go": `package a
+
+import "example.com/m/d"
+
+var _ = d.D{}
+`,
+		"b/t.go":      "package b\n\ntype T struct{}\n",
+		"b/method.go": "package b\n\nfunc (T) Method() {}\n",
+		"b/outer.go":  "package b\n\ntype Outer struct {\n\tInner\n}\n",
+		"b/inner.go":  "package b\n\ntype Inner struct {\n\tField int\n}\n",
+		"b/i.go":      "package b\n\ntype I interface {\n\tM()\n}\n",
+		"b/alias.go":  "package b\n\nimport \"example.com/m/d\"\n\ntype Alias = d.D\n",
+		"d/d.go":      "package d\n\ntype D struct{}\n",
+		// import cycle
+		"c/c.go": "package c\n\nimport \"example.com/m/e\"\n\nvar C = e.E\n",
+		"e/e.go": "package e\n\nimport \"example.com/m/c\"\n\nconst E = 1\n\nfunc F() any { return c.C }\n",
+	}
+	fset, pkgsByDir := parseModule(t, moduleRootDir, files)
+
+	result := typecheck.Check(fset, "example.com/m", moduleRootDir, pkgsByDir, &build.Default)
+	for _, err := range result.Errors {
+		t.Errorf("unexpected type error: %s", err)
+	}
+
+	type reference struct {
+		file, ident, importPath, declFile, declName string
+	}
+	relPath := func(path string) string {
+		if strings.HasPrefix(path, runtime.GOROOT()) {
+			return "GOROOT"
+		}
+		rel, err := filepath.Rel(moduleRootDir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filepath.ToSlash(rel)
+	}
+	actual := make(map[reference]struct{})
+	for _, ref := range result.References {
+		actual[reference{
+			file:       relPath(ref.AbsPath),
+			ident:      ref.Ident.Name,
+			importPath: ref.ImportPath,
+			declFile:   relPath(ref.DeclAbsPath),
+			declName:   ref.DeclName,
+		}] = struct{}{}
+		if !result.Resolved(ref.Ident) {
+			t.Errorf("%s: %s: expected resolved", ref.AbsPath, ref.Ident.Name)
+		}
+	}
+
+	expected := []reference{
+		{"a/a.go", "I", "example.com/m/b", "b/i.go", "I"},
+		{"a/a.go", "T", "example.com/m/b", "b/t.go", "T"},
+		{"a/a.go", "Method", "example.com/m/b", "b/method.go", "T.Method"},
+		{"a/a.go", "Outer", "example.com/m/b", "b/outer.go", "Outer"},
+		{"a/a.go", "Field", "example.com/m/b", "b/inner.go", "Inner"},
+		{"a/a.go", "Alias", "example.com/m/b", "b/alias.go", "Alias"},
+		{"a/a.go", "Println", "fmt", "GOROOT", "Println"},
+		{"a/a_test.go", "D", "example.com/m/d", "d/d.go", "D"},
+		{"b/alias.go", "D", "example.com/m/d", "d/d.go", "D"},
+		{"c/c.go", "E", "example.com/m/e", "e/e.go", "E"},
+		{"e/e.go", "C", "example.com/m/c", "c/c.go", "C"},
+	}
+	for _, ref := range expected {
+		if _, ok := actual[ref]; !ok {
+			t.Errorf("missing reference %v", ref)
+		}
+	}
+	for ref := range actual {
+		// references within a package are not references to another package
+		if ref.file == "b/outer.go" {
+			t.Errorf("unexpected reference %v", ref)
+		}
+	}
+}
+
+func TestCheck_PrimitiveBuilder(t *testing.T) {
+	moduleRootDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": `package a
+
+import "example.com/m/b"
+
+const X = 1
+
+func A() {
+	var v b.T
+	v.Method()
+}
+`,
+		"b/t.go":      "package b\n\ntype T struct{}\n",
+		"b/method.go": "package b\n\nimport \"example.com/m/a\"\n\nfunc (T) Method() { _ = a.X }\n",
+	}
+	fset, pkgsByDir := parseModule(t, moduleRootDir, files)
+
+	result := typecheck.Check(fset, "example.com/m", moduleRootDir, pkgsByDir, &build.Default)
+
+	builder := internalAST.NewPrimitiveBuilder("example.com/m", moduleRootDir)
+	depVis, nodeOut := internalAST.NewDependencyVisitor()
+	go func() {
+		defer depVis.Close()
+		for _, dir := range []string{"a", "b"} {
+			for _, pkg := range pkgsByDir[filepath.Join(moduleRootDir, dir)] {
+				ast.Walk(depVis, pkg)
+			}
+		}
+	}()
+	var err error
+	for node := range nodeOut {
+		if err != nil {
+			continue
+		}
+		switch node := node.(type) {
+		case *internalAST.SelectorExpr:
+			if result.Resolved(node.Sel) {
+				continue
+			}
+		case *internalAST.Ident:
+			if result.Resolved(node.Ident) {
+				continue
+			}
+		}
+		err = builder.AddNode(node)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range result.References {
+		err = builder.AddNode(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = builder.MarkupImportCycles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{
+		"a/a.go":      true,
+		"b/t.go":      false,
+		"b/method.go": true,
+	}
+	for _, file := range builder.Files() {
+		if file.IsStub {
+			continue
+		}
+		rel, err := filepath.Rel(moduleRootDir, file.AbsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inCycle, ok := expected[filepath.ToSlash(rel)]
+		if !ok {
+			t.Errorf("unexpected file %s", rel)
+			continue
+		}
+		if file.InImportCycle != inCycle {
+			t.Errorf("%s: expected in import cycle %t, got %t", rel, inCycle, file.InImportCycle)
+		}
+	}
+}
+
+func TestCheck_BuildContext(t *testing.T) {
+	depDir := t.TempDir()
+	depFiles := map[string]string{
+		"go.mod":      "module example.com/dep\n\ngo 1.21\n",
+		"tagged.go":   "//go:build integration\n\npackage dep\n\nfunc Tagged() {}\n",
+		"untagged.go": "//go:build !integration\n\npackage dep\n\nfunc Untagged() {}\n",
+	}
+	for name, data := range depFiles {
+		err := os.WriteFile(filepath.Join(depDir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moduleRootDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => " + filepath.ToSlash(depDir) + "\n",
+		"a/a.go": "package a\n\nimport \"example.com/dep\"\n\nfunc A() {\n\tdep.Tagged()\n}\n",
+	}
+	fset, pkgsByDir := parseModule(t, moduleRootDir, files)
+
+	buildContext := build.Default
+	buildContext.BuildTags = []string{"integration"}
+	result := typecheck.Check(fset, "example.com/m", moduleRootDir, pkgsByDir, &buildContext)
+	for _, err := range result.Errors {
+		t.Errorf("unexpected type error: %s", err)
+	}
+	if len(result.References) != 1 {
+		t.Fatalf("expected 1 reference, got %d", len(result.References))
+	}
+	ref := result.References[0]
+	if ref.DeclName != "Tagged" || ref.DeclAbsPath != filepath.Join(depDir, "tagged.go") {
+		t.Errorf("expected reference to Tagged in tagged.go, got %s in %s", ref.DeclName, ref.DeclAbsPath)
+	}
+}
+
+func parseModule(
+	t *testing.T,
+	moduleRootDir string,
+	files map[string]string,
+) (*token.FileSet, map[string]map[string]*ast.Package) {
+	dirs := make(map[string]struct{})
+	for name, data := range files {
+		path := filepath.Join(moduleRootDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0770)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, ".go") {
+			dirs[filepath.Dir(path)] = struct{}{}
+		}
+	}
+
+	fset := token.NewFileSet()
+	pkgsByDir := make(map[string]map[string]*ast.Package)
+	for dirPath := range dirs {
+		pkgs, err := parser.ParseDir(fset, dirPath, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgsByDir[dirPath] = pkgs
+	}
+	return fset, pkgsByDir
+}
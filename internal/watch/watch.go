@@ -0,0 +1,109 @@
+// Package watch polls the directories of a module for changes.
+package watch
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/directory"
+)
+
+// Snapshot maps the directories of a module to the stamps of the files
+// which can change its analysis, see directory.Stamp.
+type Snapshot map[string]string
+
+// Take walks the directories below root selected by cfg and stamps their .go,
+// go.mod, and .gitignore files.
+func Take(ctx context.Context, cfg *config.Config, root string) (Snapshot, error) {
+	emitter, dirOut := directory.NewEmitter()
+	emitter.SetGitIgnore(cfg.GitIgnore)
+	if err := emitter.SetInclude(cfg.Include); err != nil {
+		return nil, err
+	}
+	if err := emitter.SetExclude(cfg.Exclude); err != nil {
+		return nil, err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer emitter.Close()
+		errs <- emitter.Walk(ctx, root)
+	}()
+
+	snapshot := make(Snapshot)
+	for dirPath := range dirOut {
+		snapshot[dirPath] = directory.Stamp(dirPath, watched)
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// watched reports whether the file name can change the analysis of its
+// directory.
+func watched(name string) bool {
+	return directory.IsGoFile(name) || name == "go.mod" || name == ".gitignore"
+}
+
+// Changed returns the sorted directories added, removed, or changed since
+// prev.
+func (s Snapshot) Changed(prev Snapshot) []string {
+	var changed []string
+	for dirPath, stamp := range s {
+		prevStamp, ok := prev[dirPath]
+		if !ok || prevStamp != stamp {
+			changed = append(changed, dirPath)
+		}
+	}
+	for dirPath := range prev {
+		if _, ok := s[dirPath]; !ok {
+			changed = append(changed, dirPath)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// Poll takes a snapshot every interval until ctx is done, calling changed
+// with the changed directories whenever it differs from the previous one.
+// changed is called with no directories after the first snapshot. A snapshot
+// which cannot be taken, e.g. because a directory is removed while walking,
+// is taken again at the next interval.
+func Poll(
+	ctx context.Context,
+	interval time.Duration,
+	take func(ctx context.Context) (Snapshot, error),
+	changed func(dirs []string),
+) error {
+	prev, err := take(ctx)
+	if err != nil {
+		return err
+	}
+	changed(nil)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		snapshot, err := take(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		dirs := snapshot.Changed(prev)
+		if len(dirs) == 0 {
+			continue
+		}
+		prev = snapshot
+		changed(dirs)
+	}
+}
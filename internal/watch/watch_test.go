@@ -0,0 +1,97 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samlitowitz/goimportcycle/internal/config"
+	"github.com/samlitowitz/goimportcycle/internal/watch"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshot_Changed(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "go.mod"), "module example.com/m\n")
+	writeFile(t, filepath.Join(root, "a", "a.go"), "package a\n")
+	writeFile(t, filepath.Join(root, "b", "b.go"), "package b\n")
+	writeFile(t, filepath.Join(root, "c", "c.go"), "package c\n")
+	cfg := config.Default()
+
+	prev, err := watch.Take(context.Background(), cfg, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed := prev.Changed(prev); len(changed) != 0 {
+		t.Errorf("expected no changes, got %v", changed)
+	}
+
+	// a file is written, a package added and removed, and a non-Go file
+	// added
+	writeFile(t, filepath.Join(root, "a", "a.go"), "package a\n\nvar V int\n")
+	writeFile(t, filepath.Join(root, "d", "d.go"), "package d\n")
+	if err := os.RemoveAll(filepath.Join(root, "b")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "c", "README"), "c\n")
+
+	snapshot, err := watch.Take(context.Background(), cfg, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(root, "a"),
+		filepath.Join(root, "b"),
+		filepath.Join(root, "d"),
+	}
+	actual := snapshot.Changed(prev)
+	if !cmp.Equal(expected, actual) {
+		t.Error(cmp.Diff(expected, actual))
+	}
+}
+
+func TestPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshots := []watch.Snapshot{
+		{"/m": "1"},
+		{"/m": "1"},
+		{"/m": "2"},
+	}
+	take := func(ctx context.Context) (watch.Snapshot, error) {
+		snapshot := snapshots[0]
+		if len(snapshots) > 1 {
+			snapshots = snapshots[1:]
+		}
+		return snapshot, nil
+	}
+
+	var calls [][]string
+	err := watch.Poll(ctx, time.Millisecond, take, func(dirs []string) {
+		calls = append(calls, dirs)
+		if len(calls) == 2 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	expected := [][]string{nil, {"/m"}}
+	if !cmp.Equal(expected, calls) {
+		t.Error(cmp.Diff(expected, calls))
+	}
+}